@@ -47,7 +47,33 @@ The above example becomes:
 It does not matter whether the add function returns an int, a uint32, a byte or
 a float64. Eq and Neq compare values in a deep way while handling different
 integer types, floating point accuracy, INF and NAN and comparison between
-string, []byte and []rune.
+string, []byte and []rune. This extends to the elements of slices, arrays and
+maps, e.g. []int32{1, 2} is equal to [2]float64{1, 2}.
+Values of types with an Equal(T) bool or a Cmp(T) int method, like time.Time
+and *big.Int, are compared using that method. Nil and empty values are
+considered equal, e.g. a nil map is equal to an empty map and to an untyped
+nil, see StrictNil if you need to tell them apart.
+
+If two nested values differ, the error message lists the path to every
+mismatch instead of printing the whole values, e.g.
+
+	.Orders[3].Items["sku-1"].Price: 9.99 != 9.98
+
+Differing multi-line strings are shown as a unified line diff and for
+single-line strings a caret marks the first differing rune. Binary data, i.e.
+strings or byte slices that are not valid UTF-8 text, is shown as a hex dump
+around the differing bytes.
+
+Struct fields can be tagged to control how they are compared. Fields tagged
+with check:"-" are ignored, check:"eps=1e-3" sets the epsilon for a field and
+check:"unordered" compares a slice field without regard to order, e.g.
+
+	type Measurement struct {
+		ID    int       `check:"-"`
+		Value float64   `check:"eps=1e-3"`
+		Tags  []string  `check:"unordered"`
+		Data  []float64 `check:"eps=0.1,unordered"`
+	}
 
 This package will not solve all your testing needs but probably 95% of it. You
 can still write if-statements or special helpers for the cases where simple
@@ -59,7 +85,12 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"math/cmplx"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -77,9 +108,26 @@ type helper interface {
 	Helper()
 }
 
+// fataler is implemented by *testing.T. The Must functions use it to stop the
+// test when a check fails.
+type fataler interface {
+	Fatalf(format string, a ...interface{})
+}
+
+// failNower is used by the Must functions if the Tester does not have a Fatalf
+// method.
+type failNower interface {
+	FailNow()
+}
+
 // Eq compares a and b and calls Errorf on t if they differ. Values are compared
 // in a deep way, similar to reflect.DeepEqual, only that float and complex
 // values are compared using an epsilon of 1e-6.
+// Since float32 values above 16 are spaced further apart than 1e-6, float32 and
+// complex64 values are also equal if their difference is at most 2^-23 times
+// the larger of their absolute values, which allows them to be one float32
+// step apart. This also applies if only one of the values is a float32 or
+// complex64.
 // If there are any msg parameters, they are printed in concatenation before the
 // error message, e.g. if you pass ["input ", 5] as msg, errors will be printed
 // as: "input 5: <error>".
@@ -87,12 +135,13 @@ func Eq(t Tester, a, b interface{}, msg ...interface{}) {
 	if h, ok := t.(helper); ok {
 		h.Helper()
 	}
-	EqEps(t, a, b, 1e-6, msg...)
+	EqOpt(t, a, b, Message(msg...))
 }
 
 // EqExact compares a and b and calls Errorf on t if they differ. Values are
 // compared in a deep way, similar to reflect.DeepEqual, float and complex
-// values must match exactly.
+// values must match exactly. Integers are compared exactly to float and complex
+// values as well, even beyond 2^53 where float64 cannot represent all integers.
 // If there are any msg parameters, they are printed in concatenation before the
 // error message, e.g. if you pass ["input ", 5] as msg, errors will be printed
 // as: "input 5: <error>".
@@ -108,6 +157,9 @@ func EqExact(t Tester, a, b interface{}, msg ...interface{}) {
 // complex values are compared using epsilon. Values are considered equal if
 // their absolute difference is less than or equal to epsilon. Set epsilon to
 // zero to compare for exact equality (or use EqExact).
+// Integers are compared to float and complex values by converting them to
+// float64, which rounds integers beyond 2^53. With an epsilon of zero they are
+// compared exactly instead.
 // If there are any msg parameters, they are printed in concatenation before the
 // error message, e.g. if you pass ["input ", 5] as msg, errors will be printed
 // as: "input 5: <error>".
@@ -115,14 +167,13 @@ func EqEps(t Tester, a, b interface{}, epsilon float64, msg ...interface{}) {
 	if h, ok := t.(helper); ok {
 		h.Helper()
 	}
-	if !deepEqual(a, b, epsilon) {
-		errorf(t, "!=", a, b, msg...)
-	}
+	EqOpt(t, a, b, Epsilon(epsilon), Message(msg...))
 }
 
 // Neq compares a and b and calls Errorf on t if they are equal. Values are
 // compared in a deep way, similar to reflect.DeepEqual, only that float and
-// complex values are compared using an epsilon of 1e-6.
+// complex values are compared using an epsilon of 1e-6, with the same
+// exception for float32 and complex64 values that Eq makes.
 // If there are any msg parameters, they are printed in concatenation before the
 // error message, e.g. if you pass ["input ", 5] as msg, errors will be printed
 // as: "input 5: <error>".
@@ -130,7 +181,7 @@ func Neq(t Tester, a, b interface{}, msg ...interface{}) {
 	if h, ok := t.(helper); ok {
 		h.Helper()
 	}
-	NeqEps(t, a, b, 1e-6, msg...)
+	NeqOpt(t, a, b, Message(msg...))
 }
 
 // NeqExact compares a and b and calls Errorf on t if they are equal. Values are
@@ -158,25 +209,343 @@ func NeqEps(t Tester, a, b interface{}, epsilon float64, msg ...interface{}) {
 	if h, ok := t.(helper); ok {
 		h.Helper()
 	}
-	if deepEqual(a, b, epsilon) {
-		errorf(t, "==", a, b, msg...)
+	NeqOpt(t, a, b, Epsilon(epsilon), Message(msg...))
+}
+
+// EqOpt compares a and b and calls Errorf on t if they differ. Without any
+// options, EqOpt behaves exactly like Eq. Pass options to change how values are
+// compared, e.g.
+//
+//	check.EqOpt(t, a, b, check.RelativeEpsilon(1e-9), check.IgnoreUnexported())
+//
+// Use the Message option to prefix the error message, like the msg parameters
+// of Eq do.
+func EqOpt(t Tester, a, b interface{}, opts ...Option) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	c := newComparer(opts)
+	if diffs := c.diff(a, b); len(diffs) > 0 {
+		errorf(t, formatDiffs(diffs, maxReportedDiffs), c.msg...)
+	}
+}
+
+// NeqOpt compares a and b and calls Errorf on t if they are equal. Without any
+// options, NeqOpt behaves exactly like Neq. See EqOpt for how to use options.
+func NeqOpt(t Tester, a, b interface{}, opts ...Option) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	c := newComparer(opts)
+	if c.deepEqual(a, b) {
+		errorf(t, fmt.Sprintf("%#v == %#v", a, b), c.msg...)
+	}
+}
+
+// MustEq is like Eq but stops the test if a and b differ. It calls Fatalf on t
+// if t has such a method, otherwise it calls Errorf and then FailNow. If t has
+// neither method, MustEq panics.
+func MustEq(t Tester, a, b interface{}, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	MustEqOpt(t, a, b, Message(msg...))
+}
+
+// MustEqExact is like EqExact but stops the test if a and b differ, see MustEq.
+func MustEqExact(t Tester, a, b interface{}, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	MustEqEps(t, a, b, 0, msg...)
+}
+
+// MustEqEps is like EqEps but stops the test if a and b differ, see MustEq.
+func MustEqEps(t Tester, a, b interface{}, epsilon float64, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	MustEqOpt(t, a, b, Epsilon(epsilon), Message(msg...))
+}
+
+// MustNeq is like Neq but stops the test if a and b are equal, see MustEq.
+func MustNeq(t Tester, a, b interface{}, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	MustNeqOpt(t, a, b, Message(msg...))
+}
+
+// MustNeqExact is like NeqExact but stops the test if a and b are equal, see
+// MustEq.
+func MustNeqExact(t Tester, a, b interface{}, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	MustNeqEps(t, a, b, 0, msg...)
+}
+
+// MustNeqEps is like NeqEps but stops the test if a and b are equal, see
+// MustEq.
+func MustNeqEps(t Tester, a, b interface{}, epsilon float64, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
 	}
+	MustNeqOpt(t, a, b, Epsilon(epsilon), Message(msg...))
 }
 
-func errorf(t Tester, op string, a, b interface{}, msg ...interface{}) {
+// MustEqOpt is like EqOpt but stops the test if a and b differ, see MustEq.
+func MustEqOpt(t Tester, a, b interface{}, opts ...Option) {
 	if h, ok := t.(helper); ok {
 		h.Helper()
 	}
-	var prefix string
+	c := newComparer(opts)
+	if diffs := c.diff(a, b); len(diffs) > 0 {
+		fatalf(t, formatDiffs(diffs, maxReportedDiffs), c.msg...)
+	}
+}
+
+// MustNeqOpt is like NeqOpt but stops the test if a and b are equal, see
+// MustEq.
+func MustNeqOpt(t Tester, a, b interface{}, opts ...Option) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	c := newComparer(opts)
+	if c.deepEqual(a, b) {
+		fatalf(t, fmt.Sprintf("%#v == %#v", a, b), c.msg...)
+	}
+}
+
+// Equal reports whether a and b are equal under the same rules that EqEps uses.
+// Use it to compare values outside of tests.
+func Equal(a, b interface{}, epsilon float64) bool {
+	return EqualOpt(a, b, Epsilon(epsilon))
+}
+
+// Diff compares a and b like Equal and describes how they differ. Every line of
+// the result names the path to one mismatch, followed by the two differing
+// values, e.g.
+//
+//	.Orders[3].Items["sku-1"].Price: 9.99 != 9.98
+//
+// Diff returns the empty string if a and b are equal.
+func Diff(a, b interface{}, epsilon float64) string {
+	return DiffOpt(a, b, Epsilon(epsilon))
+}
+
+// EqualOpt reports whether a and b are equal under the same rules that EqOpt
+// uses with the given options.
+func EqualOpt(a, b interface{}, opts ...Option) bool {
+	return newComparer(opts).deepEqual(a, b)
+}
+
+// DiffOpt is like Diff but compares a and b under the same rules that EqOpt
+// uses with the given options.
+func DiffOpt(a, b interface{}, opts ...Option) string {
+	diffs := newComparer(opts).diff(a, b)
+	return formatDiffs(diffs, len(diffs))
+}
+
+func errorf(t Tester, text string, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	t.Errorf("%s", failure(text, msg...))
+}
+
+func fatalf(t Tester, text string, msg ...interface{}) {
+	if h, ok := t.(helper); ok {
+		h.Helper()
+	}
+	text = failure(text, msg...)
+	if f, ok := t.(fataler); ok {
+		f.Fatalf("%s", text)
+		return
+	}
+	t.Errorf("%s", text)
+	if f, ok := t.(failNower); ok {
+		f.FailNow()
+		return
+	}
+	panic("check: Tester has neither Fatalf nor FailNow, stopping the test: " + text)
+}
+
+// failure returns the error message for a failed check. If there are any msg
+// parameters, they are printed in concatenation before text.
+func failure(text string, msg ...interface{}) string {
 	if len(msg) > 0 {
-		prefix = fmt.Sprint(msg...) + ": "
+		return fmt.Sprint(msg...) + ": " + text
 	}
-	t.Errorf("%s%#v %s %#v", prefix, a, op, b)
+	return text
+}
+
+// maxReportedDiffs limits the number of differences that are listed in a
+// failure message.
+const maxReportedDiffs = 20
+
+// difference describes a single mismatch found by deepValueEqual. path is the
+// location of the mismatch inside the compared values, e.g.
+// `.Orders[3].Items["sku-1"].Price`, it is empty for the top-level values.
+// An invalid a or b means that the value does not exist on that side, e.g. a
+// map key that is missing.
+type difference struct {
+	path string
+	a, b reflect.Value
+	// text replaces the default "a != b" description if it is not empty.
+	text string
+}
+
+func (d difference) String() string {
+	text := d.text
+	if text == "" {
+		text = formatValue(d.a) + " != " + formatValue(d.b)
+	}
+	if d.path == "" {
+		return text
+	}
+	return d.path + ": " + text
+}
+
+// formatDiffs lists the given differences, one per line. If there are more
+// than max differences, only the first max are listed.
+func formatDiffs(diffs []difference, max int) string {
+	var lines []string
+	for i, d := range diffs {
+		if i == max {
+			lines = append(lines, fmt.Sprintf(
+				"... and %d more differences", len(diffs)-max,
+			))
+			break
+		}
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatValue formats v like %#v would. Values that were read from unexported
+// struct fields cannot be turned into an interface{}, in that case we let fmt
+// handle the reflect.Value itself.
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<missing>"
+	}
+	if v.CanInterface() {
+		return fmt.Sprintf("%#v", v.Interface())
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// comparer holds the options and the state of a deep comparison.
+type comparer struct {
+	msg                []interface{}
+	eps                float64
+	defaultEps         bool // true as long as no Epsilon option was given
+	relEps             float64
+	ulps               uint64
+	ignoreUnexported   bool
+	ownPackage         string // set by IgnoreForeignUnexported
+	ignoreEqualMethods bool
+	fieldsByName       bool
+	structsToMaps      bool
+	jsonTags           bool
+	ignorePaths        map[string]bool
+	ignorePatterns     []string
+	ignoreTypes        map[reflect.Type]bool
+	comparers          map[reflect.Type]reflect.Value
+	transformers       map[reflect.Type]reflect.Value
+	unordered          bool
+	intTolerance       bool
+	floatMode          int
+	complexModulus     bool
+	strictNil          bool
+
+	visited map[visit]bool
+	// If record is true, deepValueEqual does not stop at the first mismatch
+	// but collects all of them in diffs.
+	record bool
+	diffs  []difference
+	// activeTransformers holds the input types of the transformers whose
+	// results are being compared, for v1 and v2, see transformedEqual.
+	activeTransformers [2]map[reflect.Type]bool
+}
+
+func newComparer(opts []Option) *comparer {
+	c := &comparer{eps: 1e-6, defaultEps: true}
+	for _, opt := range opts {
+		opt(c)
+	}
+	// The relative and ULP tolerances replace the default epsilon. Only an
+	// explicit Epsilon is combined with them.
+	if c.defaultEps && (c.relEps != 0 || c.ulps != 0) {
+		c.eps = 0
+		c.defaultEps = false
+	}
+	return c
+}
+
+// diff compares x and y like deepEqual and returns all differences between
+// them. The result is empty if x and y are equal.
+func (c *comparer) diff(x, y interface{}) []difference {
+	c.record = true
+	c.diffs = nil
+	c.deepEqual(x, y)
+	return c.diffs
 }
 
 // deepEqual is a modified version of reflect.DeepEqual. deepEqual compares
-// float and complex values using epsilon.
-func deepEqual(x, y interface{}, epsilon float64) bool {
+// float and complex values using an epsilon and handles comparisons between
+// different numeric types and between strings, []byte and []rune.
+func (c *comparer) deepEqual(x, y interface{}) (equal bool) {
+	c.visited = make(map[visit]bool)
+	if c.record {
+		// For the top-level values we keep their interface type so they are
+		// formatted exactly like the values that were passed to us.
+		a := reflect.ValueOf(&x).Elem()
+		b := reflect.ValueOf(&y).Elem()
+		n := len(c.diffs)
+		defer func() {
+			if !equal && len(c.diffs) == n {
+				c.diffs = append(c.diffs, difference{a: a, b: b})
+			}
+		}()
+	}
+	_, xIsMatcher := x.(Matcher)
+	_, yIsMatcher := y.(Matcher)
+	if xIsMatcher || yIsMatcher {
+		// Matchers might also match nil.
+		return c.deepValueEqual(reflect.ValueOf(&x).Elem(), reflect.ValueOf(&y).Elem(), "")
+	}
+	if x == nil || y == nil {
+		return c.nilEqual(x, y)
+	}
+	return c.deepValueEqual(addressable(x), addressable(y), "")
+}
+
+// addressable returns a reflect.Value of a copy of x that can be addressed. This
+// way all values that we reach from it are addressable as well, which lets us
+// access values in unexported struct fields, see interfaceable.
+func addressable(x interface{}) reflect.Value {
+	v := reflect.New(reflect.TypeOf(x)).Elem()
+	v.Set(reflect.ValueOf(x))
+	return v
+}
+
+// interfaceable returns v in a form that we can call Interface or methods on.
+// This is not allowed for values that were read from unexported struct fields,
+// unless they are addressable. It returns false if this is not possible.
+func interfaceable(v reflect.Value) (reflect.Value, bool) {
+	if v.CanInterface() {
+		return v, true
+	}
+	if v.CanAddr() {
+		return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem(), true
+	}
+	return v, false
+}
+
+// nilEqual compares x and y if at least one of them is nil.
+func (c *comparer) nilEqual(x, y interface{}) bool {
 	if x == nil && y == nil {
 		return true
 	}
@@ -185,57 +554,141 @@ func deepEqual(x, y interface{}, epsilon float64) bool {
 	}
 	if x == nil {
 		// y is not nil
-		y := reflect.ValueOf(y)
-		if y.Kind() == reflect.Slice {
-			return y.IsNil() || y.Len() == 0
+		return !c.strictNil && isNilOrEmpty(reflect.ValueOf(y))
+	}
+	return false
+}
+
+// isNilOrEmpty reports whether v is a nil pointer, channel, function or
+// interface, or a nil or empty slice or map. Unless StrictNil is set, these
+// values are equal to an untyped nil.
+func isNilOrEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.IsNil() || v.Len() == 0
+	case reflect.Ptr, reflect.Chan, reflect.Func, reflect.Interface:
+		return v.IsNil()
+	case reflect.UnsafePointer:
+		return v.Pointer() == 0
+	}
+	return false
+}
+
+// deepValueEqual compares v1 and v2 which are located at the given path inside
+// the top-level values. If c.record is set, every mismatch is appended to
+// c.diffs. Container types record the differences of their elements, anything
+// else is recorded here as a mismatch of v1 and v2 as a whole.
+func (c *comparer) deepValueEqual(v1, v2 reflect.Value, path string) (equal bool) {
+	if c.ignores(path, v1, v2) {
+		return true
+	}
+	// Values from unexported struct fields cannot be used with Interface or
+	// Call and neither can map values and dynamic values of interfaces that we
+	// get from them. Unlike those, the values from unexported fields are
+	// addressable so we turn them into regular values right here, before we
+	// get to their maps and interfaces.
+	v1, _ = interfaceable(v1)
+	v2, _ = interfaceable(v2)
+	if c.record {
+		a, b := v1, v2
+		n := len(c.diffs)
+		defer func() {
+			if !equal && len(c.diffs) == n {
+				c.diffs = append(c.diffs, difference{path: path, a: a, b: b})
+			}
+		}()
+	}
+
+	if t1, t2, applied := c.transform(v1, v2); applied != [2]reflect.Type{} {
+		return c.transformedEqual(v1, v2, t1, t2, applied, path)
+	}
+
+	if m, ok := asMatcher(v2); ok {
+		return m.match(c, dynamicValue(v1), path)
+	}
+	if m, ok := asMatcher(v1); ok {
+		return m.match(c, dynamicValue(v2), path)
+	}
+
+	if v1.Type() == v2.Type() {
+		if equal, ok := c.equalByComparer(v1, v2); ok {
+			return equal
+		}
+		if !c.ignoreEqualMethods {
+			if equal, ok := equalByMethod(v1, v2); ok {
+				return equal
+			}
 		}
-		if y.Kind() == reflect.Ptr {
-			return y.IsNil()
+	}
+
+	if c.comparesAsStrings(v1, v2) {
+		if c.strictNil && v1.Kind() == reflect.Slice && v2.Kind() == reflect.Slice &&
+			v1.IsNil() != v2.IsNil() {
+			return false
+		}
+		b1, b2 := toBytes(v1), toBytes(v2)
+		if bytes.Equal(b1, b2) {
+			return true
+		}
+		if c.record {
+			c.diffs = append(c.diffs, stringDifference(path, v1, v2, b1, b2))
 		}
 		return false
 	}
-	return deepValueEqual(
-		reflect.ValueOf(x),
-		reflect.ValueOf(y),
-		epsilon,
-		make(map[visit]bool),
-	)
-}
 
-func deepValueEqual(v1, v2 reflect.Value, eps float64, visited map[visit]bool) bool {
 	if v1.Type() != v2.Type() {
-		if canBeString(v1) && canBeString(v2) {
-			return bytes.Equal(toBytes(v1), toBytes(v2))
+		// An interface is compared by its dynamic value.
+		if v1.Kind() == reflect.Interface && !v1.IsNil() {
+			return c.deepValueEqual(v1.Elem(), v2, path)
 		}
-		if isInteger(v1) && isInteger(v2) {
-			// We might need to compare signed with unsigned.
-			v1Signed := isSignedInteger(v1)
-			if v1Signed != isSignedInteger(v2) {
-				// One signed, one unsigned; make sure the unsigned type is v1.
-				if v1Signed {
-					v1, v2 = v2, v1
-				}
-				// v1: unsigned type
-				// v2: signed type
-				i2 := v2.Int()
-				if i2 < 0 {
-					return false // v2 is unsigned, thus always >= 0
-				}
-				u1 := v1.Uint()
-				return u1 == uint64(i2)
+		if v2.Kind() == reflect.Interface && !v2.IsNil() {
+			return c.deepValueEqual(v1, v2.Elem(), path)
+		}
+		if v1.Kind() == reflect.Interface || v2.Kind() == reflect.Interface {
+			// A nil interface is like an untyped nil, see nilEqual.
+			return !c.strictNil && isNilOrEmpty(v1) && isNilOrEmpty(v2)
+		}
+		if isSequence(v1) && isSequence(v2) {
+			return c.sequencesEqual(v1, v2, path)
+		}
+		if v1.Kind() == reflect.Map && v2.Kind() == reflect.Map {
+			return c.mapsEqual(v1, v2, path)
+		}
+		if c.fieldsByName &&
+			v1.Kind() == reflect.Struct && v2.Kind() == reflect.Struct {
+			return c.fieldsByNameEqual(v1, v2, path)
+		}
+		if c.fieldsByName && isStructPointer(v1) && isStructPointer(v2) {
+			return c.structPointersEqual(v1, v2, path)
+		}
+		if c.structsToMaps && isStructPointer(v1) && isStringMap(v2) {
+			if v1.IsNil() {
+				return v2.IsNil()
 			}
-			// At this point either both are signed or both are unsigned, thus
-			// their uint64 bit patterns should match.
-			return toUint64(v1) == toUint64(v2)
+			return c.deepValueEqual(v1.Elem(), v2, path)
 		}
+		if c.structsToMaps && isStringMap(v1) && isStructPointer(v2) {
+			if v2.IsNil() {
+				return v1.IsNil()
+			}
+			return c.deepValueEqual(v1, v2.Elem(), path)
+		}
+		if c.structsToMaps &&
+			(v1.Kind() == reflect.Struct && isStringMap(v2) ||
+				isStringMap(v1) && v2.Kind() == reflect.Struct) {
+			return c.structMapEqual(v1, v2, path)
+		}
+		if isInteger(v1) && isInteger(v2) {
+			return c.intEq(v1, v2)
+		}
+		// If one of the values has single precision, we compare with single
+		// precision.
+		single := isSingle(v1) || isSingle(v2)
 		if isFloat(v1) && isFloat(v2) {
-			return floatEq(v1.Float(), v2.Float(), eps)
+			return c.floatValuesEq(v1, v2)
 		}
 		if isComplex(v1) && isComplex(v2) {
-			c1 := v1.Complex()
-			c2 := v2.Complex()
-			return floatEq(real(c1), real(c2), eps) &&
-				floatEq(imag(c1), imag(c2), eps)
+			return c.complexValuesEq(v1, v2)
 		}
 		// check for int/float to complex comparison, make the complex be v2
 		if isComplex(v1) {
@@ -245,16 +698,19 @@ func deepValueEqual(v1, v2 reflect.Value, eps float64, visited map[visit]bool) b
 			c2 := v2.Complex()
 			if imag(c2) != 0 {
 				// imaginary part is non-zero, but for integer and floats it
-				// must be zero
-				return false
+				// must be zero, unless the modulus of the difference is within
+				// our tolerance
+				if !c.complexModulus || !(isInteger(v1) || isFloat(v1)) {
+					return false
+				}
+				return c.complexEq(complex(numberToFloat64(v1), 0), c2, single)
 			}
 			f2 := real(c2)
 			if isInteger(v1) {
-				f1 := intToFloat64(v1)
-				return floatEq(f1, f2, eps)
+				return c.intFloatEq(v1, f2, single)
 			} else if isFloat(v1) {
 				f1 := v1.Float()
-				return floatEq(f1, f2, eps)
+				return c.floatEq(f1, f2, single)
 			} else {
 				return false
 			}
@@ -264,9 +720,7 @@ func deepValueEqual(v1, v2 reflect.Value, eps float64, visited map[visit]bool) b
 			v1, v2 = v2, v1
 		}
 		if isInteger(v1) && isFloat(v2) {
-			f1 := intToFloat64(v1)
-			f2 := v2.Float()
-			return floatEq(f1, f2, eps)
+			return c.intFloatEq(v1, v2.Float(), single)
 		}
 		return false
 	}
@@ -296,88 +750,69 @@ func deepValueEqual(v1, v2 reflect.Value, eps float64, visited map[visit]bool) b
 		// Short circuit if references are already seen.
 		typ := v1.Type()
 		v := visit{addr1, addr2, typ}
-		if visited[v] {
+		if c.visited[v] {
 			return true
 		}
 
-		// Remember for later.
-		visited[v] = true
+		// Remember for later. Values that turn out to be different must be
+		// forgotten again, otherwise they would be considered equal when we
+		// compare them again, e.g. when matching unordered elements.
+		c.visited[v] = true
+		defer func() {
+			if !equal {
+				delete(c.visited, v)
+			}
+		}()
 	}
 
 	switch v1.Kind() {
-	case reflect.Array:
-		// v1 and v2 have the same type, the length of the array is part of its
-		// type, thus we need not compare their lengths.
-		for i := 0; i < v1.Len(); i++ {
-			if !deepValueEqual(v1.Index(i), v2.Index(i), eps, visited) {
-				return false
-			}
-		}
-		return true
-	case reflect.Slice:
-		if v1.IsNil() && v2.Len() == 0 {
-			return true
-		}
-		if v1.Len() == 0 && v2.IsNil() {
-			return true
-		}
-		if v1.IsNil() != v2.IsNil() {
-			return false
-		}
-		if v1.Len() != v2.Len() {
-			return false
-		}
-		if v1.Pointer() == v2.Pointer() {
+	case reflect.Array, reflect.Slice:
+		return c.sequencesEqual(v1, v2, path)
+	case reflect.Interface:
+		if v1.IsNil() && v2.IsNil() {
 			return true
 		}
-		for i := 0; i < v1.Len(); i++ {
-			if !deepValueEqual(v1.Index(i), v2.Index(i), eps, visited) {
-				return false
-			}
-		}
-		return true
-	case reflect.Interface:
 		if v1.IsNil() || v2.IsNil() {
-			return v1.IsNil() == v2.IsNil()
+			// A nil interface is like an untyped nil, see nilEqual.
+			// The Elem of the nil interface is invalid and not nil or empty.
+			return !c.strictNil &&
+				(isNilOrEmpty(v1.Elem()) || isNilOrEmpty(v2.Elem()))
 		}
-		return deepValueEqual(v1.Elem(), v2.Elem(), eps, visited)
+		return c.deepValueEqual(v1.Elem(), v2.Elem(), path)
 	case reflect.Ptr:
 		if v1.Pointer() == v2.Pointer() {
 			return true
 		}
-		return deepValueEqual(v1.Elem(), v2.Elem(), eps, visited)
+		if v1.IsNil() || v2.IsNil() {
+			return false
+		}
+		return c.deepValueEqual(v1.Elem(), v2.Elem(), path)
 	case reflect.Struct:
+		equal := true
 		for i, n := 0, v1.NumField(); i < n; i++ {
-			if !deepValueEqual(v1.Field(i), v2.Field(i), eps, visited) {
-				return false
+			field := v1.Type().Field(i)
+			if c.skipsUnexported(field) {
+				continue
 			}
-		}
-		return true
-	case reflect.Map:
-		if v1.IsNil() != v2.IsNil() {
-			return false
-		}
-		if v1.Len() != v2.Len() {
-			return false
-		}
-		if v1.Pointer() == v2.Pointer() {
-			return true
-		}
-		for _, k := range v1.MapKeys() {
-			val1 := v1.MapIndex(k)
-			val2 := v2.MapIndex(k)
-			if !val1.IsValid() ||
-				!val2.IsValid() ||
-				!deepValueEqual(v1.MapIndex(k), v2.MapIndex(k), eps, visited) {
-				return false
+			fieldPath := c.fieldPath(path, field.Name)
+			if !c.fieldEqual(parseFieldTag(field), v1.Field(i), v2.Field(i), fieldPath) {
+				equal = false
+				if !c.record {
+					return false
+				}
 			}
 		}
-		return true
+		return equal
+	case reflect.Map:
+		return c.mapsEqual(v1, v2, path)
 	case reflect.Func:
 		if v1.IsNil() && v2.IsNil() {
 			return true
 		}
 		return v1.Pointer() == v2.Pointer()
+	case reflect.Chan:
+		// Channels are equal if they are the same channel or both nil.
+		return v1.Pointer() == v2.Pointer()
 	case reflect.Bool:
 		return v2.Kind() == reflect.Bool && v1.Bool() == v2.Bool()
 	case reflect.Uint,
@@ -392,25 +827,22 @@ func deepValueEqual(v1, v2 reflect.Value, eps float64, visited map[visit]bool) b
 			k2 == reflect.Uint16 ||
 			k2 == reflect.Uint32 ||
 			k2 == reflect.Uint64 ||
-			k2 == reflect.Uintptr) && v1.Uint() == v2.Uint()
+			k2 == reflect.Uintptr) && c.intEq(v1, v2)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		k2 := v2.Kind()
 		return (k2 == reflect.Int ||
 			k2 == reflect.Int8 ||
 			k2 == reflect.Int16 ||
 			k2 == reflect.Int32 ||
-			k2 == reflect.Int64) && v1.Int() == v2.Int()
+			k2 == reflect.Int64) && c.intEq(v1, v2)
 	case reflect.Float32, reflect.Float64:
 		k2 := v2.Kind()
 		return (k2 == reflect.Float32 || k2 == reflect.Float64) &&
-			floatEq(v1.Float(), v2.Float(), eps)
+			c.floatValuesEq(v1, v2)
 	case reflect.Complex64, reflect.Complex128:
 		k2 := v2.Kind()
 		return (k2 == reflect.Complex64 || k2 == reflect.Complex128) &&
-			floatEq(real(v1.Complex()), real(v2.Complex()), eps) &&
-			floatEq(imag(v1.Complex()), imag(v2.Complex()), eps)
-	case reflect.String:
-		return v2.Kind() == reflect.String && v1.String() == v2.String()
+			c.complexValuesEq(v1, v2)
 	case reflect.UnsafePointer:
 		return v2.Kind() == reflect.UnsafePointer && v1.Pointer() == v2.Pointer()
 	default:
@@ -418,6 +850,602 @@ func deepValueEqual(v1, v2 reflect.Value, eps float64, visited map[visit]bool) b
 	}
 }
 
+// equalByMethod compares v1 and v2, which have the same type T, using their
+// method
+//
+//	Equal(T) bool
+//
+// like time.Time has, or, if there is no such method, using
+//
+//	Cmp(T) int
+//
+// like *big.Int has. It returns false for ok if neither method exists or cannot
+// be called.
+func equalByMethod(v1, v2 reflect.Value) (equal, ok bool) {
+	t := v1.Type()
+	if t.Kind() == reflect.Interface {
+		// We look at the dynamic values instead.
+		return false, false
+	}
+	if t.Kind() == reflect.Ptr && (v1.IsNil() || v2.IsNil()) {
+		return false, false
+	}
+	isMethod := func(m reflect.Method, out reflect.Kind) bool {
+		typ := m.Type // the receiver is the first input
+		return typ.NumIn() == 2 && typ.In(1) == t &&
+			typ.NumOut() == 1 && typ.Out(0).Kind() == out
+	}
+	method, found := t.MethodByName("Equal")
+	if !found || !isMethod(method, reflect.Bool) {
+		method, found = t.MethodByName("Cmp")
+		if !found || !isMethod(method, reflect.Int) {
+			return false, false
+		}
+	}
+	v1, ok1 := interfaceable(v1)
+	v2, ok2 := interfaceable(v2)
+	if !ok1 || !ok2 {
+		return false, false
+	}
+	result := method.Func.Call([]reflect.Value{v1, v2})[0]
+	if result.Kind() == reflect.Bool {
+		return result.Bool(), true
+	}
+	return result.Int() == 0, true
+}
+
+// needsPaths reports whether the paths to compared values are needed, which is
+// the case when recording differences or checking IgnorePaths. Building paths
+// is expensive, e.g. map keys are formatted, so plain checks do without them.
+func (c *comparer) needsPaths() bool {
+	return c.record || c.ignoresPaths()
+}
+
+// indexPath returns the path to the element at index i of the array or slice
+// at path, if needed.
+func (c *comparer) indexPath(path string, i int) string {
+	if !c.needsPaths() {
+		return path
+	}
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// keyPath returns the path to the value at key k of the map at path, if
+// needed.
+func (c *comparer) keyPath(path string, k reflect.Value) string {
+	if !c.needsPaths() {
+		return path
+	}
+	return path + "[" + formatValue(k) + "]"
+}
+
+// fieldPath returns the path to the field with the given name of the struct at
+// path, if needed.
+func (c *comparer) fieldPath(path, name string) string {
+	if !c.needsPaths() {
+		return path
+	}
+	return path + "." + name
+}
+
+// elementsEqual compares the first n elements of the arrays or slices v1 and
+// v2.
+func (c *comparer) elementsEqual(v1, v2 reflect.Value, n int, path string) bool {
+	equal := true
+	for i := 0; i < n; i++ {
+		elemPath := c.indexPath(path, i)
+		if !c.deepValueEqual(v1.Index(i), v2.Index(i), elemPath) {
+			equal = false
+			if !c.record {
+				return false
+			}
+		}
+	}
+	return equal
+}
+
+// sequencesEqual compares the arrays or slices v1 and v2 element by element.
+// Their types may differ, e.g. a []int can be equal to a [3]float64.
+func (c *comparer) sequencesEqual(v1, v2 reflect.Value, path string) bool {
+	isNil := func(v reflect.Value) bool {
+		return v.Kind() == reflect.Slice && v.IsNil()
+	}
+	if v1.Len() == 0 && v2.Len() == 0 {
+		return !c.strictNil || isNil(v1) == isNil(v2)
+	}
+	if v1.Len() == v2.Len() && v1.Kind() == reflect.Slice &&
+		v1.Type() == v2.Type() && v1.Pointer() == v2.Pointer() {
+		return true
+	}
+	if c.unordered {
+		return c.unorderedEqual(v1, v2, path)
+	}
+	if c.record && readableAsString(v1) && readableAsString(v2) {
+		// Byte and rune slices are compared element by element but their
+		// differences are shown like those of strings.
+		c.record = false
+		equal := v1.Len() == v2.Len() && c.elementsEqual(v1, v2, v1.Len(), path)
+		c.record = true
+		if !equal {
+			c.diffs = append(c.diffs, stringDifference(path, v1, v2, toBytes(v1), toBytes(v2)))
+		}
+		return equal
+	}
+	if v1.Len() != v2.Len() {
+		if !c.record {
+			return false
+		}
+		// Report the elements that both sides have in common as well, they
+		// might give a hint about what went wrong.
+		c.diffs = append(c.diffs, difference{
+			path: path,
+			a:    v1,
+			b:    v2,
+			text: fmt.Sprintf("length %d != %d", v1.Len(), v2.Len()),
+		})
+		n := v1.Len()
+		if v2.Len() < n {
+			n = v2.Len()
+		}
+		c.elementsEqual(v1, v2, n, path)
+		return false
+	}
+	return c.elementsEqual(v1, v2, v1.Len(), path)
+}
+
+// unorderedEqual compares the elements of the arrays or slices v1 and v2 as
+// multisets, i.e. every element of v1 must be equal to a distinct element of v2
+// and vice versa. Since our equality is not transitive, e.g. for floats with an
+// epsilon, we find a maximum matching of equal elements instead of pairing them
+// greedily. If they differ, the elements that are missing in v1 and the extra
+// elements in v1 are reported.
+func (c *comparer) unorderedEqual(v1, v2 reflect.Value, path string) bool {
+	n, m := v1.Len(), v2.Len()
+	if n != m && !c.record {
+		return false
+	}
+
+	match, unmatched := maximumMatching(n, m, func(i, j int) bool {
+		elemPath := c.indexPath(path, i)
+		return c.quietEqual(v1.Index(i), v2.Index(j), elemPath)
+	}, !c.record)
+	if len(unmatched) > 0 && !c.record {
+		return false
+	}
+	var extra []string
+	for _, i := range unmatched {
+		extra = append(extra, formatValue(v1.Index(i)))
+	}
+	var missing []string
+	for j, i := range match {
+		if i == -1 {
+			missing = append(missing, formatValue(v2.Index(j)))
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return true
+	}
+
+	if c.record {
+		text := "unordered elements differ"
+		if len(missing) > 0 {
+			text += "\nmissing: " + strings.Join(missing, ", ")
+		}
+		if len(extra) > 0 {
+			text += "\nextra: " + strings.Join(extra, ", ")
+		}
+		c.diffs = append(c.diffs, difference{path: path, a: v1, b: v2, text: text})
+	}
+	return false
+}
+
+// maximumMatching pairs the indices 0 to n-1 of one side with the indices 0 to
+// m-1 of the other side so that as many pairs as possible are equal. It uses
+// Kuhn's algorithm so unlike pairing them greedily, the result does not depend
+// on the order of the indices. equal is called at most once for every pair.
+// match[j] is the index that j is paired with, or -1, and unmatched lists the
+// indices i without a partner. If stop is true, it returns as soon as there is
+// an unmatched index.
+func maximumMatching(n, m int, equal func(i, j int) bool, stop bool) (match, unmatched []int) {
+	// Comparisons are expensive so we only compare two elements when we need
+	// to know whether they are equal, and only once.
+	known := make(map[[2]int]bool)
+	isEqual := func(i, j int) bool {
+		eq, ok := known[[2]int{i, j}]
+		if !ok {
+			eq = equal(i, j)
+			known[[2]int{i, j}] = eq
+		}
+		return eq
+	}
+
+	match = make([]int, m)
+	for j := range match {
+		match[j] = -1
+	}
+	var tried []bool
+	var augment func(i int) bool
+	augment = func(i int) bool {
+		// Start looking at the same index so that sequences in the same order
+		// are matched in linear time.
+		for k := 0; k < m; k++ {
+			j := (i + k) % m
+			if tried[j] || !isEqual(i, j) {
+				continue
+			}
+			tried[j] = true
+			if match[j] == -1 || augment(match[j]) {
+				match[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < n; i++ {
+		tried = make([]bool, m)
+		if !augment(i) {
+			unmatched = append(unmatched, i)
+			if stop {
+				break
+			}
+		}
+	}
+	return match, unmatched
+}
+
+// mapsEqual compares the maps v1 and v2. Their types may differ, e.g. a
+// map[string]int can be equal to a map[string]float64, see convertedMapsEqual
+// for maps with different key types.
+func (c *comparer) mapsEqual(v1, v2 reflect.Value, path string) bool {
+	if !c.strictNil && v1.Len() == 0 && v2.Len() == 0 {
+		return true
+	}
+	if v1.IsNil() != v2.IsNil() {
+		return false
+	}
+	// Entries that only exist on one side might be ignored, so we can only
+	// stop early if there are no IgnorePaths.
+	if v1.Len() != v2.Len() && !c.record && !c.ignoresPaths() {
+		return false
+	}
+	if v1.Type() == v2.Type() && v1.Pointer() == v2.Pointer() {
+		return true
+	}
+
+	if v1.Type().Key() != v2.Type().Key() {
+		return c.convertedMapsEqual(v1, v2, path)
+	}
+
+	equal := true
+	for _, k := range c.mapKeys(v1) {
+		keyPath := c.keyPath(path, k)
+		value1, value2 := v1.MapIndex(k), v2.MapIndex(k)
+		if !value2.IsValid() {
+			if !c.missingEntry(keyPath, value1, value2) {
+				if !c.record {
+					return false
+				}
+				equal = false
+			}
+		} else if !c.deepValueEqual(value1, value2, keyPath) {
+			if !c.record {
+				return false
+			}
+			equal = false
+		}
+	}
+	if !c.record && !c.ignoresPaths() {
+		// All keys of v1 are in v2 and both have the same length.
+		return true
+	}
+
+	// Report the keys that are only in v2.
+	for _, k := range c.mapKeys(v2) {
+		value1 := v1.MapIndex(k)
+		if !value1.IsValid() {
+			keyPath := c.keyPath(path, k)
+			if !c.missingEntry(keyPath, value1, v2.MapIndex(k)) {
+				if !c.record {
+					return false
+				}
+				equal = false
+			}
+		}
+	}
+	return equal
+}
+
+// missingEntry handles a map entry at path that only exists on one side, i.e.
+// either v1 or v2 is invalid. It returns true if the entry is ignored,
+// otherwise the entry is recorded as a difference.
+func (c *comparer) missingEntry(path string, v1, v2 reflect.Value) bool {
+	if c.ignores(path, v1, v2) {
+		return true
+	}
+	if c.record {
+		c.diffs = append(c.diffs, difference{path: path, a: v1, b: v2})
+	}
+	return false
+}
+
+// convertedMapsEqual compares the maps v1 and v2 whose key types differ. Every
+// entry of v1 must be equal to a distinct entry of v2, i.e. both their keys and
+// their values must be equal. Since keys might be equal to more than one key
+// on the other side, e.g. floats within an epsilon, we find a maximum matching
+// of equal entries so that the result does not depend on the order of the map
+// keys.
+func (c *comparer) convertedMapsEqual(v1, v2 reflect.Value, path string) bool {
+	keys1 := c.mapKeys(v1)
+	keys2 := c.mapKeys(v2)
+	// Entries that only exist on one side might be ignored, so we can only
+	// stop early if there are no IgnorePaths.
+	stop := !c.record && !c.ignoresPaths()
+	match, unmatched := maximumMatching(len(keys1), len(keys2), func(i, j int) bool {
+		return c.quietEqual(keys1[i], keys2[j], "") &&
+			c.quietEqual(v1.MapIndex(keys1[i]), v2.MapIndex(keys2[j]), c.keyPath(path, keys1[i]))
+	}, stop)
+	if stop {
+		return len(unmatched) == 0 && len(keys1) == len(keys2)
+	}
+
+	// The entries of v1 that are left over are either paired with a leftover
+	// entry of v2 with an equal key, so we can report their differing values,
+	// or they are missing in v2.
+	matched := make([]bool, len(keys2))
+	for j, i := range match {
+		matched[j] = i != -1
+	}
+	equal := true
+	for _, i := range unmatched {
+		k1 := keys1[i]
+		found := false
+		for j, k2 := range keys2 {
+			if !matched[j] && c.quietEqual(k1, k2, "") {
+				matched[j] = true
+				found = true
+				equal = false
+				c.deepValueEqual(v1.MapIndex(k1), v2.MapIndex(k2), c.keyPath(path, k1))
+				break
+			}
+		}
+		if !found && !c.missingEntry(c.keyPath(path, k1), v1.MapIndex(k1), reflect.Value{}) {
+			equal = false
+		}
+		if !equal && !c.record {
+			return false
+		}
+	}
+	// Report the keys that are only in v2.
+	for j, k2 := range keys2 {
+		if !matched[j] && !c.missingEntry(c.keyPath(path, k2), reflect.Value{}, v2.MapIndex(k2)) {
+			if !c.record {
+				return false
+			}
+			equal = false
+		}
+	}
+	return equal
+}
+
+// skipsUnexported reports whether field is an unexported field that is ignored
+// because of IgnoreUnexported or IgnoreForeignUnexported.
+func (c *comparer) skipsUnexported(field reflect.StructField) bool {
+	if field.PkgPath == "" {
+		return false
+	}
+	if c.ignoreUnexported {
+		return true
+	}
+	// Types declared in the external test package count as our own.
+	pkg := strings.TrimSuffix(field.PkgPath, "_test")
+	return c.ownPackage != "" && pkg != c.ownPackage
+}
+
+// structPointersEqual compares the pointers v1 and v2 to structs of different
+// types by the structs that they point to. Two nil pointers are equal, a nil
+// pointer is not equal to a non-nil pointer.
+func (c *comparer) structPointersEqual(v1, v2 reflect.Value, path string) (equal bool) {
+	if v1.IsNil() || v2.IsNil() {
+		return v1.IsNil() && v2.IsNil()
+	}
+	// The pointers might form a cycle, see the visited map in deepValueEqual.
+	s1, s2 := v1.Elem(), v2.Elem()
+	v := visit{unsafe.Pointer(s1.UnsafeAddr()), unsafe.Pointer(s2.UnsafeAddr()), s1.Type()}
+	if c.visited[v] {
+		return true
+	}
+	c.visited[v] = true
+	defer func() {
+		if !equal {
+			delete(c.visited, v)
+		}
+	}()
+	return c.deepValueEqual(s1, s2, path)
+}
+
+// fieldsByNameEqual compares the structs v1 and v2, which have different types,
+// by matching their exported fields by name. Fields of embedded structs are
+// promoted, see namedFields. Fields that only exist on one side make the
+// structs differ.
+func (c *comparer) fieldsByNameEqual(v1, v2 reflect.Value, path string) bool {
+	fields1 := namedFields(v1.Type(), false)
+	fields2 := namedFields(v2.Type(), false)
+	byName1 := make(map[string]namedField)
+	for _, f := range fields1 {
+		byName1[f.name] = f
+	}
+	byName2 := make(map[string]namedField)
+	for _, f := range fields2 {
+		byName2[f.name] = f
+	}
+
+	equal := true
+	// missing handles a field that only exists on one side. It returns false
+	// if we can stop comparing.
+	missing := func(d difference) bool {
+		if c.ignores(d.path, d.a, d.b) {
+			return true
+		}
+		if c.record {
+			c.diffs = append(c.diffs, d)
+		}
+		equal = false
+		return c.record
+	}
+	for _, f1 := range fields1 {
+		f2, inBoth := byName2[f1.name]
+		tag := f1.tag
+		if inBoth {
+			tag = tag.merge(f2.tag)
+		}
+		if tag.ignore {
+			continue
+		}
+		fieldPath := c.fieldPath(path, f1.name)
+		// A field that is promoted through a nil pointer does not exist.
+		a, ok1 := fieldByIndex(v1, f1.field.Index)
+		var b reflect.Value
+		ok2 := false
+		if inBoth {
+			b, ok2 = fieldByIndex(v2, f2.field.Index)
+		}
+		if !ok1 && !ok2 {
+			continue
+		}
+		if !ok1 || !ok2 {
+			if !missing(difference{path: fieldPath, a: a, b: b}) {
+				return false
+			}
+			continue
+		}
+		if !c.fieldEqual(tag, a, b, fieldPath) {
+			equal = false
+			if !c.record {
+				return false
+			}
+		}
+	}
+	for _, f2 := range fields2 {
+		if _, inBoth := byName1[f2.name]; inBoth || f2.tag.ignore {
+			continue
+		}
+		b, ok := fieldByIndex(v2, f2.field.Index)
+		if ok && !missing(difference{path: c.fieldPath(path, f2.name), b: b}) {
+			return false
+		}
+	}
+	return equal
+}
+
+// structMapEqual compares a struct and a map with string keys, one of them is
+// v1, the other one is v2. The exported struct fields are matched with the map
+// keys by their names or their json tags, if enabled. Fields of embedded
+// structs are promoted, see namedFields. Every field must have a key and vice
+// versa.
+func (c *comparer) structMapEqual(v1, v2 reflect.Value, path string) bool {
+	structFirst := v1.Kind() == reflect.Struct
+	s, m := v1, v2
+	if !structFirst {
+		s, m = m, s
+	}
+	// pair orders a struct and a map value like v1 and v2 are ordered.
+	pair := func(sv, mv reflect.Value) (reflect.Value, reflect.Value) {
+		if structFirst {
+			return sv, mv
+		}
+		return mv, sv
+	}
+
+	equal := true
+	// missing handles a field or key that only exists on one side. It returns
+	// false if we can stop comparing.
+	missing := func(d difference) bool {
+		if c.ignores(d.path, d.a, d.b) {
+			return true
+		}
+		if c.record {
+			c.diffs = append(c.diffs, d)
+		}
+		equal = false
+		return c.record
+	}
+
+	keyType := m.Type().Key()
+	used := make(map[string]bool)
+	for _, f := range namedFields(s.Type(), c.jsonTags) {
+		used[f.name] = true
+		if f.tag.ignore {
+			continue
+		}
+		fieldPath := c.fieldPath(path, f.field.Name)
+		// A field that is promoted through a nil pointer does not exist.
+		sv, ok := fieldByIndex(s, f.field.Index)
+		mv := m.MapIndex(reflect.ValueOf(f.name).Convert(keyType))
+		if !ok && !mv.IsValid() {
+			continue
+		}
+		if !ok || !mv.IsValid() {
+			a, b := pair(sv, mv)
+			if !missing(difference{path: fieldPath, a: a, b: b}) {
+				return false
+			}
+			continue
+		}
+		a, b := pair(sv, mv)
+		if !c.fieldEqual(f.tag, a, b, fieldPath) {
+			equal = false
+			if !c.record {
+				return false
+			}
+		}
+	}
+	for _, k := range c.mapKeys(m) {
+		if !used[k.String()] {
+			a, b := pair(reflect.Value{}, m.MapIndex(k))
+			d := difference{path: c.keyPath(path, k), a: a, b: b}
+			if !missing(d) {
+				return false
+			}
+		}
+	}
+	return equal
+}
+
+// mapKeys returns the keys of the map v. When recording differences, we sort
+// them so the report is deterministic.
+func (c *comparer) mapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	if c.record {
+		sort.Slice(keys, func(i, j int) bool {
+			return formatValue(keys[i]) < formatValue(keys[j])
+		})
+	}
+	return keys
+}
+
+// quietEqual compares v1 and v2 without recording any differences. We use it
+// when trying to match up values, which produces mismatches that we do not want
+// to report.
+func (c *comparer) quietEqual(v1, v2 reflect.Value, path string) bool {
+	record := c.record
+	c.record = false
+	defer func() { c.record = record }()
+	return c.deepValueEqual(v1, v2, path)
+}
+
+func isStringMap(v reflect.Value) bool {
+	return v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String
+}
+
+func isStructPointer(v reflect.Value) bool {
+	return v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct
+}
+
+func isSequence(v reflect.Value) bool {
+	k := v.Kind()
+	return k == reflect.Array || k == reflect.Slice
+}
+
 func isInteger(v reflect.Value) bool {
 	return isSignedInteger(v) || isUnsignedInteger(v)
 }
@@ -471,11 +1499,250 @@ type visit struct {
 	typ reflect.Type
 }
 
-func floatEq(a, b, eps float64) bool {
-	return math.IsInf(a, 1) && math.IsInf(b, 1) ||
-		math.IsInf(a, -1) && math.IsInf(b, -1) ||
-		math.IsNaN(a) && math.IsNaN(b) ||
-		abs(a-b) <= eps
+// isSingle reports whether v is a float32 or complex64.
+func isSingle(v reflect.Value) bool {
+	k := v.Type().Kind()
+	return k == reflect.Float32 || k == reflect.Complex64
+}
+
+// float32Epsilon is the relative spacing of float32 values, i.e. the distance
+// from 1 to the next larger float32.
+const float32Epsilon = 1.0 / (1 << 23)
+
+// These are the modes for comparing special float values, see StrictFloats and
+// IEEEFloats.
+const (
+	defaultFloats = iota // NaN equals NaN, +0 equals -0
+	strictFloats         // NaN, zero and infinity bits must match
+	ieeeFloats           // NaN equals nothing, +0 equals -0
+)
+
+// floatEq compares a and b using the absolute, relative and ULP tolerances of
+// c. Values that are within any of these tolerances are equal. If single is
+// true, the values come from float32 or complex64 values, ULPs are counted in
+// steps of float32 values and the default epsilon is widened to the float32
+// precision. NaNs, infinities and zeros are compared according to the float
+// mode of c.
+func (c *comparer) floatEq(a, b float64, single bool) bool {
+	nan := math.IsNaN(a) || math.IsNaN(b)
+	switch {
+	case c.floatMode == strictFloats && (nan || a == 0 && b == 0):
+		return math.Float64bits(a) == math.Float64bits(b)
+	case nan:
+		return c.floatMode != ieeeFloats && math.IsNaN(a) && math.IsNaN(b)
+	case math.IsInf(a, 0) || math.IsInf(b, 0):
+		// Infinities must not be within a tolerance of finite values.
+		return a == b
+	}
+	return abs(a-b) <= c.eps ||
+		abs(a-b) <= c.relEps*math.Max(abs(a), abs(b)) ||
+		c.defaultEps && single &&
+			abs(a-b) <= float32Epsilon*math.Max(abs(a), abs(b)) ||
+		c.ulps > 0 && ulpDistance(a, b, single) <= c.ulps
+}
+
+// floatValuesEq compares the float values v1 and v2, see floatEq.
+func (c *comparer) floatValuesEq(v1, v2 reflect.Value) bool {
+	if c.floatMode == strictFloats &&
+		v1.Kind() == reflect.Float32 && v2.Kind() == reflect.Float32 {
+		a, _ := float32Bits(v1)
+		b, _ := float32Bits(v2)
+		return c.strictFloat32Eq(a, b)
+	}
+	return c.floatEq(v1.Float(), v2.Float(), isSingle(v1) || isSingle(v2))
+}
+
+// complexValuesEq compares the complex values v1 and v2, see complexEq.
+func (c *comparer) complexValuesEq(v1, v2 reflect.Value) bool {
+	if c.floatMode == strictFloats &&
+		v1.Kind() == reflect.Complex64 && v2.Kind() == reflect.Complex64 {
+		re1, im1 := float32Bits(v1)
+		re2, im2 := float32Bits(v2)
+		if isSpecialFloat32(re1, re2) || isSpecialFloat32(im1, im2) {
+			return c.strictFloat32Eq(re1, re2) && c.strictFloat32Eq(im1, im2)
+		}
+	}
+	return c.complexEq(v1.Complex(), v2.Complex(), isSingle(v1) || isSingle(v2))
+}
+
+// complexEq compares a and b by comparing their real and imaginary parts with
+// floatEq. With ComplexModulus, the distance |a-b| is compared to the absolute
+// and relative tolerances instead. Values with special parts, e.g. NaN, are
+// always compared part by part.
+func (c *comparer) complexEq(a, b complex128, single bool) bool {
+	if !c.complexModulus ||
+		c.isSpecialFloat(real(a), real(b)) || c.isSpecialFloat(imag(a), imag(b)) {
+		return c.floatEq(real(a), real(b), single) &&
+			c.floatEq(imag(a), imag(b), single)
+	}
+	d := cmplx.Abs(a - b)
+	largest := math.Max(cmplx.Abs(a), cmplx.Abs(b))
+	return d <= c.eps ||
+		d <= c.relEps*largest ||
+		c.defaultEps && single && d <= float32Epsilon*largest
+}
+
+// isSpecialFloat reports whether comparing a and b depends on the float mode of
+// c, see floatEq.
+func (c *comparer) isSpecialFloat(a, b float64) bool {
+	return math.IsNaN(a) || math.IsNaN(b) || math.IsInf(a, 0) || math.IsInf(b, 0) ||
+		c.floatMode == strictFloats && a == 0 && b == 0
+}
+
+// float32Bits returns the bits of the float32 value v or of the real and
+// imaginary parts of the complex64 value v. Converting float32 values to
+// float64 might change the payload of NaNs, so we read them from memory.
+// Values that are not addressable, e.g. map values or the dynamic values of
+// interfaces, are copied into a variable first.
+func float32Bits(v reflect.Value) (re, im uint32) {
+	if !v.CanAddr() && v.CanInterface() {
+		addressable := reflect.New(v.Type()).Elem()
+		addressable.Set(v)
+		v = addressable
+	}
+	if v.CanAddr() {
+		p := unsafe.Pointer(v.UnsafeAddr())
+		if v.Kind() == reflect.Float32 {
+			return *(*uint32)(p), 0
+		}
+		parts := *(*[2]uint32)(p)
+		return parts[0], parts[1]
+	}
+	if v.Kind() == reflect.Float32 {
+		return math.Float32bits(float32(v.Float())), 0
+	}
+	x := v.Complex()
+	return math.Float32bits(float32(real(x))), math.Float32bits(float32(imag(x)))
+}
+
+// strictFloat32Eq is floatEq in strict mode for the float32 values with the
+// bits a and b.
+func (c *comparer) strictFloat32Eq(a, b uint32) bool {
+	if isSpecialFloat32(a, b) {
+		return a == b
+	}
+	return c.floatEq(float64(math.Float32frombits(a)), float64(math.Float32frombits(b)), true)
+}
+
+// intEq compares the integers v1 and v2, which may be signed or unsigned. With
+// IntegerTolerance they are equal if they differ by at most the absolute
+// epsilon.
+func (c *comparer) intEq(v1, v2 reflect.Value) bool {
+	d := intDistance(v1, v2)
+	if !c.intTolerance || !(c.eps >= 1) {
+		return d == 0
+	}
+	if c.eps >= 1<<64 {
+		return true
+	}
+	// d is an integer so comparing it to the truncated epsilon is exact.
+	return d <= uint64(c.eps)
+}
+
+// intDistance returns the absolute difference of the integers v1 and v2, which
+// may be signed or unsigned. Differences that do not fit into a uint64 are
+// capped at math.MaxUint64.
+func intDistance(v1, v2 reflect.Value) uint64 {
+	// We split the values into sign and magnitude to avoid overflows.
+	split := func(v reflect.Value) (negative bool, magnitude uint64) {
+		if isSignedInteger(v) && v.Int() < 0 {
+			// This also works for math.MinInt64, whose negation overflows to
+			// itself but whose bits are 1<<63 as a uint64.
+			return true, uint64(-v.Int())
+		}
+		return false, toUint64(v)
+	}
+	neg1, m1 := split(v1)
+	neg2, m2 := split(v2)
+	if neg1 == neg2 {
+		if m1 < m2 {
+			return m2 - m1
+		}
+		return m1 - m2
+	}
+	if m1 > math.MaxUint64-m2 {
+		return math.MaxUint64
+	}
+	return m1 + m2
+}
+
+// intFloatEq compares the integer v with the float f. Without any tolerance
+// they are compared exactly. Otherwise v is converted to a float64, which
+// rounds integers beyond 2^53, and compared to f like two floats.
+func (c *comparer) intFloatEq(v reflect.Value, f float64, single bool) bool {
+	if c.eps == 0 && c.relEps == 0 && c.ulps == 0 {
+		return intEqualsFloat(v, f)
+	}
+	return c.floatEq(intToFloat64(v), f, single)
+}
+
+// intEqualsFloat reports whether the integer v has exactly the value f.
+func intEqualsFloat(v reflect.Value, f float64) bool {
+	if math.IsInf(f, 0) || f != math.Trunc(f) {
+		return false // this includes NaN
+	}
+	// The range checks make sure that f can be converted to an integer. The
+	// bounds are powers of two, which are exact as floats.
+	if isSignedInteger(v) {
+		if f < math.MinInt64 || f >= -math.MinInt64 {
+			return false
+		}
+		return int64(f) == v.Int()
+	}
+	if f < 0 || f >= 1<<64 {
+		return false
+	}
+	return uint64(f) == v.Uint()
+}
+
+// isSpecialFloat32 is isSpecialFloat in strict mode for the float32 values
+// with the bits a and b.
+func isSpecialFloat32(a, b uint32) bool {
+	const (
+		exponent = 0x7F800000
+		sign     = 0x80000000
+	)
+	nanOrInf := func(x uint32) bool { return x&exponent == exponent }
+	return nanOrInf(a) || nanOrInf(b) || a&^sign == 0 && b&^sign == 0
+}
+
+// ulpDistance returns how many steps of representable floating point values a
+// and b are apart, the distance of two neighboring values is 1. If single is
+// true, a and b are rounded to float32 and float32 values are counted.
+func ulpDistance(a, b float64, single bool) uint64 {
+	var ia, ib int64
+	if single {
+		ia = int64(orderedBits32(float32(a)))
+		ib = int64(orderedBits32(float32(b)))
+	} else {
+		ia = orderedBits64(a)
+		ib = orderedBits64(b)
+	}
+	if ia < ib {
+		ia, ib = ib, ia
+	}
+	// The difference might not fit into an int64 but it always fits into a
+	// uint64.
+	return uint64(ia) - uint64(ib)
+}
+
+// orderedBits64 maps the bits of f to an integer so that neighboring floats map
+// to neighboring integers, with +0 and -0 both mapping to 0.
+func orderedBits64(f float64) int64 {
+	bits := int64(math.Float64bits(f))
+	if bits < 0 {
+		bits = math.MinInt64 - bits
+	}
+	return bits
+}
+
+// orderedBits32 is like orderedBits64 for float32 values.
+func orderedBits32(f float32) int32 {
+	bits := int32(math.Float32bits(f))
+	if bits < 0 {
+		bits = math.MinInt32 - bits
+	}
+	return bits
 }
 
 func abs(x float64) float64 {
@@ -496,6 +1763,41 @@ func canBeString(v reflect.Value) bool {
 	return false
 }
 
+// comparesAsStrings reports whether v1 and v2 are compared as strings, i.e. as
+// their UTF-8 bytes. This is the case for strings and for byte and rune slices
+// of different types, e.g. a []byte and a []rune. Byte and rune slices of the
+// same type are compared element by element instead because converting runes
+// to UTF-8 would make all invalid runes equal. Unordered slices are compared
+// as multisets of their elements and with an IntegerTolerance, the elements are
+// compared as integers.
+func (c *comparer) comparesAsStrings(v1, v2 reflect.Value) bool {
+	if !canBeString(v1) || !canBeString(v2) {
+		return false
+	}
+	if v1.Kind() == reflect.String || v2.Kind() == reflect.String {
+		return true
+	}
+	return v1.Type() != v2.Type() && !c.unordered && !c.intTolerance
+}
+
+// readableAsString reports whether the byte or rune slice v can be shown as a
+// string in a difference. Bytes that are no text are shown as binary data but
+// runes must be text, otherwise they are shown as a list of numbers.
+func readableAsString(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice || !canBeString(v) {
+		return false
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		return true
+	}
+	for i := 0; i < v.Len(); i++ {
+		if !utf8.ValidRune(rune(v.Index(i).Int())) {
+			return false
+		}
+	}
+	return isText(toBytes(v))
+}
+
 func toBytes(v reflect.Value) []byte {
 	if v.Kind() == reflect.String {
 		return []byte(v.String())
@@ -503,6 +1805,12 @@ func toBytes(v reflect.Value) []byte {
 	if v.Type().Elem().Kind() == reflect.Uint8 {
 		return v.Bytes()
 	}
-	// in this case we have a rune slice
-	return []byte(string(v.Interface().([]rune)))
+	// In this case we have a rune slice. Its type might be a named type or it
+	// might come from an unexported field so we cannot convert it with
+	// Interface().([]rune).
+	runes := make([]rune, v.Len())
+	for i := range runes {
+		runes[i] = rune(v.Index(i).Int())
+	}
+	return []byte(string(runes))
 }
@@ -1,8 +1,15 @@
 package check_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/gonutz/check"
@@ -21,6 +28,24 @@ func (t *mockTester) Helper() {
 	t.isHelper = true
 }
 
+type mockFatalTester struct {
+	mockTester
+	fatal string
+}
+
+func (t *mockFatalTester) Fatalf(format string, a ...interface{}) {
+	t.fatal = fmt.Sprintf(format, a...)
+}
+
+type mockFailNowTester struct {
+	mockTester
+	failedNow bool
+}
+
+func (t *mockFailNowTester) FailNow() {
+	t.failedNow = true
+}
+
 func TestEqAndNeq(t *testing.T) {
 	// eq and neq are helper functions that let us state facts about values that
 	// are equal and not equal. eq asserts that check.Eq is true for both
@@ -114,6 +139,12 @@ func TestEqAndNeq(t *testing.T) {
 	neq("abc", "ABC")
 	neq("abc", []byte("ABC"))
 	neq("abc", []rune("ABC"))
+	eq([]rune("äbc"), []rune("äbc"))
+	neq([]byte("abc"), []byte("abd"))
+	// Runes of the same type are not compared as UTF-8, which would make all
+	// invalid runes equal.
+	neq([]int32{-1}, []int32{-2})
+	neq([]int32{0xD800}, []int32{0xD801})
 
 	// functions
 	eq(eq, eq)
@@ -121,6 +152,24 @@ func TestEqAndNeq(t *testing.T) {
 	var nilF func()
 	eq(nilF, nilF)
 
+	// channels
+	ch1 := make(chan int)
+	ch2 := make(chan int)
+	var nilChan chan int
+	eq(ch1, ch1)
+	neq(ch1, ch2)
+	eq(nilChan, nilChan)
+	neq(nilChan, ch1)
+	type withChan struct {
+		Name string
+		ch   chan int
+	}
+	eq(withChan{"a", ch1}, withChan{"a", ch1})
+	eq(withChan{"a", nil}, withChan{"a", nil})
+	neq(withChan{"a", ch1}, withChan{"a", ch2})
+	neq(withChan{"a", ch1}, withChan{"a", nil})
+	neq(withChan{"a", ch1}, withChan{"b", ch1})
+
 	// arrays
 	var ints2 [2]int
 	var ints3 [3]int
@@ -138,6 +187,30 @@ func TestEqAndNeq(t *testing.T) {
 	eq([]interface{}{7.0, 6.0, 5.0}, []interface{}{7, uint8(6), 5})
 	neq([]interface{}{""}, []interface{}{5})
 
+	// slices, arrays and maps of different types
+	eq([]int32{1, 2}, []int{1, 2})
+	eq([]int{1, 2}, [2]float64{1, 2})
+	eq([2]uint8{1, 2}, [2]int64{1, 2})
+	eq([]int32(nil), []int{})
+	eq([][]int{{1}, {2, 3}}, [][]float32{{1}, {2, 3}})
+	eq([]interface{}{1, "a"}, []interface{}{1.0, []byte("a")})
+	neq([]int{1, 2}, [3]int{1, 2, 0})
+	neq([]int8{1, 2}, []uint{1, 3})
+	neq([]int{1}, map[int]int{0: 1})
+	eq(map[string]int{"a": 1}, map[string]float64{"a": 1})
+	eq(map[int]string{1: "a", 2: "b"}, map[uint8]string{2: "b", 1: "a"})
+	eq(map[int8][]int{1: {2}}, map[int][]uint{1: {2}})
+	eq(map[int]int(nil), map[uint]int{})
+	neq(map[int]string{1: "a"}, map[uint8]string{2: "a"})
+	neq(map[int]string{1: "a"}, map[uint8]string{1: "a", 2: "b"})
+	neq(map[string]int{"a": 1}, map[string]float64{"a": 1.5})
+	eq([]interface{}{1, 2}, []int{1, 2})
+	eq([]interface{}{1, 2.0}, []float32{1, 2})
+	eq(map[string]interface{}{"a": 1}, map[string]int{"a": 1})
+	eq([]interface{}{nil}, []*int{nil})
+	neq([]interface{}{1, "a"}, []int{1, 2})
+	neq([]interface{}{nil}, []int{0})
+
 	// maps
 	var nilMap map[int]string
 	m1 := map[int]string{1: "abc"}
@@ -196,6 +269,7 @@ func TestEqAndNeq(t *testing.T) {
 	eq(nil, intPtr)
 	var integer int
 	neq(&integer, nil)
+	neq(&integer, intPtr)
 
 	// empty slices are nil
 	s := make([]int, 0)
@@ -206,6 +280,39 @@ func TestEqAndNeq(t *testing.T) {
 	eq([]int{}, s)
 	eq(s, []int{})
 
+	// nil and empty values of all kinds are equal to nil
+	var nilSlice []int
+	var nilMap2 map[string]int
+	var nilChan2 chan int
+	var nilFunc func()
+	var nilInterface error
+	eq(nil, nilSlice)
+	eq(nil, nilMap2)
+	eq(nil, map[string]int{})
+	eq(nil, nilChan2)
+	eq(nil, nilFunc)
+	eq(nil, unsafe.Pointer(nil))
+	eq(nilMap2, map[string]int{})
+	eq(nilSlice, []int{})
+	neq(nil, map[string]int{"a": 1})
+	neq(nil, make(chan int))
+	neq(nil, func() {})
+	neq(nil, 0)
+	neq(nil, "")
+	neq(nil, struct{}{})
+
+	// nil interfaces behave like nil
+	eq([]interface{}{nil}, []interface{}{nilSlice})
+	eq([]interface{}{nil}, []interface{}{[]int{}})
+	eq([]interface{}{nil}, []interface{}{map[int]int{}})
+	eq([]interface{}{nil}, []interface{}{intPtr})
+	eq([]interface{}{nil}, []interface{}{nilChan2})
+	eq([]interface{}{nil}, []interface{}{nilFunc})
+	eq([]error{nilInterface}, []error{nil})
+	neq([]interface{}{nil}, []interface{}{0})
+	neq([]interface{}{nil}, []interface{}{&integer})
+	neq([]interface{}{nil}, []interface{}{[]int{1}})
+
 	// Fuzz-test different types of values, make sure Eq and Neq do not panic.
 	values := []interface{}{
 		false, true,
@@ -253,6 +360,275 @@ func TestEqHasMessage(t *testing.T) {
 	}
 }
 
+func TestEqListsPathsToDifferences(t *testing.T) {
+	type item struct {
+		Price float64
+	}
+	type order struct {
+		ID    int
+		Items map[string]item
+		Tags  []string
+	}
+	a := []order{
+		{ID: 1, Items: map[string]item{"sku-1": {9.99}, "sku-2": {1}}},
+		{ID: 2, Tags: []string{"a", "b", "c"}},
+	}
+	b := []order{
+		{ID: 1, Items: map[string]item{"sku-1": {9.98}, "sku-3": {1}}},
+		{ID: 3, Tags: []string{"a", "x"}},
+	}
+	var tt mockTester
+	check.Eq(&tt, a, b)
+	want := `[0].Items["sku-1"].Price: 9.99 != 9.98
+[0].Items["sku-2"]: check_test.item{Price:1} != <missing>
+[0].Items["sku-3"]: <missing> != check_test.item{Price:1}
+[1].ID: 2 != 3
+[1].Tags: length 3 != 2
+[1].Tags[1]: "b" != "x"
+"b"
+"x"
+ ^`
+	if tt.err != want {
+		t.Errorf("want\n%s\nbut have\n%s", want, tt.err)
+	}
+
+	tt.err = ""
+	check.Eq(&tt, struct{ a, b int }{1, 2}, struct{ a, b int }{1, 3}, "msg")
+	if tt.err != "msg: .b: 2 != 3" {
+		t.Error(tt.err)
+	}
+}
+
+// formattedKey counts how often it is formatted with %#v, which is how map keys
+// are formatted in paths.
+type formattedKey int
+
+var formattedKeys int
+
+func (k formattedKey) GoString() string {
+	formattedKeys++
+	return strconv.Itoa(int(k))
+}
+
+func TestPathsAreOnlyBuiltWhenNeeded(t *testing.T) {
+	a := map[formattedKey][]int{1: {1, 2}, 2: {3}}
+	b := map[formattedKey][]int{1: {1, 2}, 2: {3}}
+	formattedKeys = 0
+	if !check.Equal(a, b, 0) {
+		t.Error("maps should be equal")
+	}
+	if formattedKeys != 0 {
+		t.Errorf("map keys were formatted %d times", formattedKeys)
+	}
+	b[2] = []int{4}
+	if d := check.Diff(a, b, 0); d != "[2][0]: 3 != 4" {
+		t.Error(d)
+	}
+	if check.EqualOpt(a, b, check.IgnorePaths("[1]")) {
+		t.Error("only the first entry is ignored")
+	}
+	if !check.EqualOpt(a, b, check.IgnorePaths("[2][0]")) {
+		t.Error("the difference is ignored")
+	}
+}
+
+func TestEqualAndDiff(t *testing.T) {
+	if !check.Equal(1.0, 1.05, 0.1) {
+		t.Error("1.0 and 1.05 should be equal with epsilon 0.1")
+	}
+	if check.Equal(1.0, 1.05, 0.01) {
+		t.Error("1.0 and 1.05 should differ with epsilon 0.01")
+	}
+	if d := check.Diff([]float64{1, 2}, []float64{1, 2.01}, 0.1); d != "" {
+		t.Errorf("no difference expected but have %q", d)
+	}
+	type point struct{ X, Y float64 }
+	d := check.Diff(
+		[]point{{1, 2}, {3, 4}},
+		[]point{{1, 2.5}, {3.5, 4}},
+		0.1,
+	)
+	if d != "[0].Y: 2 != 2.5\n[1].X: 3 != 3.5" {
+		t.Error(d)
+	}
+	d = check.Diff(
+		map[int][]int{1: {1, 2}, 2: {3}},
+		map[uint8][]float64{1: {1, 2.5}, 3: {3}},
+		0,
+	)
+	if d != "[1][1]: 2 != 2.5\n[2]: []int{3} != <missing>\n[0x3]: <missing> != []float64{3}" {
+		t.Error(d)
+	}
+	if d := check.Diff(1, "1", 0); d != `1 != "1"` {
+		t.Error(d)
+	}
+	if d := check.Diff([]int32{1, 2}, []int32{1, 3}, 0); d != "[1]: 2 != 3" {
+		t.Error(d)
+	}
+	if d := check.Diff([]rune("ab"), []rune("ac"), 0); d != `[]int32{97, 98} != []int32{97, 99}
+"ab"
+"ac"
+  ^` {
+		t.Error(d)
+	}
+}
+
+func TestStringDifferences(t *testing.T) {
+	var tt mockTester
+	check.Eq(&tt, "hello world", []byte("hello wxrld"))
+	want := `"hello world" != []byte{0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x20, 0x77, 0x78, 0x72, 0x6c, 0x64}
+"hello world"
+"hello wxrld"
+        ^`
+	if tt.err != want {
+		t.Errorf("want\n%s\nbut have\n%s", want, tt.err)
+	}
+
+	tt.err = ""
+	check.Eq(&tt, "a\tb", "a\tc")
+	want = `"a\tb" != "a\tc"
+"a\tb"
+"a\tc"
+    ^`
+	if tt.err != want {
+		t.Errorf("want\n%s\nbut have\n%s", want, tt.err)
+	}
+
+	tt.err = ""
+	check.Eq(
+		&tt,
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12",
+		"1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n12",
+		"text",
+	)
+	want = `text: strings differ:
+--- a
++++ b
+@@ -1,6 +1,6 @@
+  1  1 | 1
+  2  2 | 2
+- 3    | 3
++    3 | three
+  4  4 | 4
+  5  5 | 5
+  6  6 | 6
+@@ -8,5 +8,4 @@
+  8  8 | 8
+  9  9 | 9
+ 10 10 | 10
+-11    | 11
+ 12 11 | 12`
+	if tt.err != want {
+		t.Errorf("want\n%s\nbut have\n%s", want, tt.err)
+	}
+}
+
+func TestBinaryDifferences(t *testing.T) {
+	a := make([]byte, 100)
+	for i := range a {
+		a[i] = byte(i)
+	}
+	b := append([]byte{}, a...)
+	b[40] = 0xFF
+	b = b[:98]
+	var tt mockTester
+	check.Eq(&tt, a, b)
+	want := `binary data differs: length 100 != 98, 3 differing bytes, first at offset 0x28
+...
+ 00000010  10 11 12 13 14 15 16 17  18 19 1a 1b 1c 1d 1e 1f  |................|
+-00000020  20 21 22 23 24 25 26 27  28 29 2a 2b 2c 2d 2e 2f  | !"#$%&'()*+,-./|
++00000020  20 21 22 23 24 25 26 27  ff 29 2a 2b 2c 2d 2e 2f  | !"#$%&'.)*+,-./|
+ 00000030  30 31 32 33 34 35 36 37  38 39 3a 3b 3c 3d 3e 3f  |0123456789:;<=>?|
+...
+ 00000050  50 51 52 53 54 55 56 57  58 59 5a 5b 5c 5d 5e 5f  |PQRSTUVWXYZ[\]^_|
+-00000060  60 61 62 63                                       |` + "`" + `abc|
++00000060  60 61                                             |` + "`" + `a|`
+	if tt.err != want {
+		t.Errorf("want\n%s\nbut have\n%s", want, tt.err)
+	}
+}
+
+func TestFloat32UsesItsOwnPrecisionByDefault(t *testing.T) {
+	a := float32(1000)
+	b := math.Nextafter32(a, 2000)
+	var tt mockTester
+	check.Eq(&tt, a, b)
+	check.Eq(&tt, complex(a, 1), complex64(complex(b, 1)))
+	check.Eq(&tt, float64(a)+3e-5, b)
+	check.Eq(&tt, 1000, b)
+	if tt.err != "" {
+		t.Error(tt.err)
+	}
+
+	check.Eq(&tt, a, math.Nextafter32(b, 2000))
+	if tt.err == "" {
+		t.Error("float32 values two steps apart must differ")
+	}
+	tt.err = ""
+	check.Neq(&tt, a, b)
+	if tt.err == "" {
+		t.Error("Neq must use the float32 precision as well")
+	}
+	tt.err = ""
+	check.EqEps(&tt, a, b, 1e-6)
+	if tt.err == "" {
+		t.Error("EqEps must use the given epsilon")
+	}
+	tt.err = ""
+	check.Eq(&tt, 1000.0, 1000.00006)
+	if tt.err == "" {
+		t.Error("float64 values must use an epsilon of 1e-6")
+	}
+}
+
+func TestEqualMethodsAreUsed(t *testing.T) {
+	utc := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	local := utc.In(time.FixedZone("UTC+1", 60*60))
+	now := time.Now()
+	type event struct {
+		Name string
+		when time.Time
+	}
+	big1 := new(big.Float).SetPrec(10).SetInt64(5)
+	big2 := new(big.Float).SetPrec(100).SetInt64(5)
+
+	var tt mockTester
+	check.Eq(&tt, utc, local)
+	check.Eq(&tt, now, now.Round(0))
+	check.Eq(&tt, event{"a", utc}, event{"a", local})
+	check.Eq(&tt, []interface{}{utc}, []interface{}{local})
+	check.Eq(&tt, big1, big2)
+	check.Eq(&tt, (*big.Float)(nil), (*big.Float)(nil))
+	// Map values and dynamic interface values inside of unexported fields
+	// are compared with their methods as well.
+	type schedule struct {
+		events map[string]time.Time
+		next   interface{}
+	}
+	check.Eq(&tt,
+		schedule{map[string]time.Time{"a": utc}, utc},
+		schedule{map[string]time.Time{"a": local}, local},
+	)
+	if tt.err != "" {
+		t.Error(tt.err)
+	}
+
+	check.Eq(&tt, utc, utc.Add(1))
+	if tt.err == "" {
+		t.Error("different times must differ")
+	}
+	tt.err = ""
+	check.Eq(&tt, big1, big.NewFloat(6))
+	if tt.err == "" {
+		t.Error("different big.Floats must differ")
+	}
+	tt.err = ""
+	check.EqOpt(&tt, utc, local, check.IgnoreEqualMethods())
+	if tt.err == "" {
+		t.Error("IgnoreEqualMethods must compare times structurally")
+	}
+}
+
 func TestEqExact(t *testing.T) {
 	var tt mockTester
 	check.EqExact(&tt, 1.0, 1.0)
@@ -277,6 +653,940 @@ func TestEqExact(t *testing.T) {
 	if tt.err != "" {
 		t.Error("no error expected", tt.err)
 	}
+	// Integers beyond 2^53 cannot all be represented as float64 values, they
+	// must not be rounded when comparing them exactly.
+	check.NeqExact(t, int64(9007199254740993), float64(9007199254740992))
+	check.NeqExact(t, uint64(9007199254740993), float64(9007199254740992))
+	check.NeqExact(t, int64(9007199254740993), complex(9007199254740992, 0))
+	check.EqExact(t, int64(9007199254740992), float64(9007199254740992))
+	check.EqExact(t, float32(1<<40), uint64(1<<40))
+	check.EqExact(t, int64(math.MinInt64), float64(math.MinInt64))
+	check.NeqExact(t, int64(math.MaxInt64), float64(math.MaxInt64))
+	check.NeqExact(t, uint64(math.MaxUint64), float64(math.MaxUint64))
+	check.EqExact(t, uint64(1<<63), float64(1<<63))
+	check.NeqExact(t, uint8(1), 1.5)
+	check.NeqExact(t, 0, math.NaN())
+	check.NeqExact(t, math.MaxInt64, math.Inf(1))
+	check.NeqExact(t, uint(0), -0.5)
+	check.EqExact(t, uint(0), math.Copysign(0, -1))
+	// With a tolerance, integers are converted to float64.
+	check.EqEps(t, int64(9007199254740993), float64(9007199254740992), 1e-9)
+}
+
+func TestMustFunctionsStopTheTest(t *testing.T) {
+	var tt mockFatalTester
+	check.MustEq(&tt, 1, 1)
+	check.MustNeq(&tt, 1, 2)
+	check.MustEqExact(&tt, 1.5, 1.5)
+	check.MustNeqExact(&tt, 1.0, 1.00000001)
+	check.MustEqEps(&tt, 1.0, 1.5, 0.5)
+	check.MustNeqEps(&tt, 1.0, 1.6, 0.5)
+	if tt.fatal != "" || tt.err != "" {
+		t.Errorf("no failure expected but have %q and %q", tt.fatal, tt.err)
+	}
+
+	check.MustEq(&tt, 1, 2, "message")
+	if tt.fatal != "message: 1 != 2" || tt.err != "" {
+		t.Errorf("want Fatalf but have %q and %q", tt.fatal, tt.err)
+	}
+	if !tt.isHelper {
+		t.Error("MustEq does not declare itself as Helper()")
+	}
+
+	tt.fatal = ""
+	check.MustNeqEps(&tt, 1.0, 1.5, 0.5)
+	if tt.fatal != "1 == 1.5" {
+		t.Error(tt.fatal)
+	}
+
+	var failNow mockFailNowTester
+	check.MustEqExact(&failNow, 1.0, 1.5)
+	if failNow.err != "1 != 1.5" || !failNow.failedNow {
+		t.Errorf("want Errorf and FailNow but have %q and %v",
+			failNow.err, failNow.failedNow)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustNeq panics without Fatalf and FailNow")
+		}
+	}()
+	var plain mockTester
+	check.MustNeq(&plain, 1, 1)
+}
+
+func TestEqOpt(t *testing.T) {
+	eqOpt := func(a, b interface{}, opts ...check.Option) {
+		t.Helper()
+		var tt mockTester
+		check.EqOpt(&tt, a, b, opts...)
+		if tt.err != "" {
+			t.Errorf("%v == %v but error for EqOpt was %q", a, b, tt.err)
+		}
+		check.NeqOpt(&tt, a, b, opts...)
+		if tt.err == "" {
+			t.Errorf("%v == %v but have no error for NeqOpt", a, b)
+		}
+	}
+	neqOpt := func(a, b interface{}, opts ...check.Option) {
+		t.Helper()
+		var tt mockTester
+		check.NeqOpt(&tt, a, b, opts...)
+		if tt.err != "" {
+			t.Errorf("%v != %v but error for NeqOpt was %q", a, b, tt.err)
+		}
+		check.EqOpt(&tt, a, b, opts...)
+		if tt.err == "" {
+			t.Errorf("%v != %v but have no error for EqOpt", a, b)
+		}
+	}
+
+	// Without options EqOpt is Eq.
+	eqOpt(1.0, 1.0000001)
+	neqOpt(1.0, 1.00001)
+
+	eqOpt(1.0, 1.05, check.Epsilon(0.1))
+	neqOpt(1.0, 1.0000001, check.Epsilon(0))
+
+	eqOpt(1e12, 1e12+1000, check.RelativeEpsilon(1e-9))
+	neqOpt(1e-12, 2e-12, check.Epsilon(0), check.RelativeEpsilon(1e-9))
+	// The relative and ULP tolerances replace the default epsilon.
+	neqOpt(1e-12, 5e-12, check.RelativeEpsilon(1e-9))
+	eqOpt(1e-12, 5e-12, check.RelativeEpsilon(1e-9), check.Epsilon(1e-6))
+	eqOpt(1e-12, 5e-12, check.Epsilon(1e-6), check.RelativeEpsilon(1e-9))
+	neqOpt(1e-12, 5e-12, check.ULPs(1))
+	eqOpt(1.0, math.Nextafter(1, 2), check.ULPs(1))
+	neqOpt(float32(1), float32(1.0000002), check.RelativeEpsilon(1e-9))
+
+	nan1 := math.Float64frombits(0x7FF8000000000001)
+	nan2 := math.Float64frombits(0x7FF8000000000002)
+	negZero := math.Copysign(0, -1)
+	strict := check.StrictFloats()
+	eqOpt(nan1, nan2)
+	eqOpt(0.0, negZero)
+	neqOpt(nan1, nan2, strict)
+	eqOpt(nan1, nan1, strict)
+	neqOpt(0.0, negZero, strict)
+	eqOpt(negZero, negZero, strict)
+	eqOpt(negZero, 1e-9, strict)
+	eqOpt(math.Inf(1), math.Inf(1), strict)
+	neqOpt(math.Inf(1), math.MaxFloat64, strict, check.ULPs(1))
+	neqOpt(math.Inf(1), 1e300, check.RelativeEpsilon(0.1))
+	neqOpt(float32(0), float32(negZero), strict)
+	quiet32 := math.Float32frombits(0x7FC00001)
+	signaling32 := math.Float32frombits(0x7F800001)
+	neqOpt(quiet32, signaling32, strict)
+	eqOpt(signaling32, signaling32, strict)
+	neqOpt(complex(quiet32, 0), complex(signaling32, 0), strict)
+	neqOpt([]interface{}{quiet32}, []interface{}{signaling32}, strict)
+	eqOpt([]interface{}{signaling32}, []interface{}{signaling32}, strict)
+	neqOpt(map[int]float32{1: quiet32}, map[int]float32{1: signaling32}, strict)
+	neqOpt(struct{ X interface{} }{quiet32}, struct{ X interface{} }{signaling32}, strict)
+	neqOpt(map[int]interface{}{1: complex(quiet32, 0)},
+		map[int]interface{}{1: complex(signaling32, 0)}, strict)
+	neqOpt(complex(0, 1), complex(negZero, 1), strict)
+	eqOpt([]float64{1, nan1}, []float64{1, nan1}, strict)
+	ieee := check.IEEEFloats()
+	neqOpt(math.NaN(), math.NaN(), ieee)
+	neqOpt(complex(math.NaN(), 0), complex(math.NaN(), 0), ieee)
+	eqOpt(0.0, negZero, ieee)
+	eqOpt(math.Inf(-1), math.Inf(-1), ieee)
+	neqOpt(math.NaN(), math.NaN(), strict, ieee)
+	eqOpt(nan1, nan1, ieee, strict)
+
+	modulus := check.ComplexModulus()
+	eps := check.Epsilon(0.1)
+	eqOpt(complex(1, 1), complex(1.09, 1.09), eps)
+	neqOpt(complex(1, 1), complex(1.09, 1.09), eps, modulus)
+	eqOpt(complex(1, 1), complex(1.07, 1.07), eps, modulus)
+	eqOpt(complex64(complex(1, 1)), complex(1.07, 1.07), eps, modulus)
+	eqOpt(complex(1000, 1000), complex(1000.5, 999.5),
+		check.Epsilon(0), check.RelativeEpsilon(1e-3), modulus)
+	neqOpt(complex(1000, 1000), complex(1002, 998),
+		check.Epsilon(0), check.RelativeEpsilon(1e-3), modulus)
+	neqOpt(1, complex(1, 0.05), eps)
+	eqOpt(1, complex(1, 0.05), eps, modulus)
+	eqOpt(complex(1, 0.05), 1.05, eps, modulus)
+	neqOpt(complex(1, 0.1), 1.1, eps, modulus)
+	neqOpt("1", complex(1, 0.05), eps, modulus)
+	eqOpt(complex(math.NaN(), 1), complex(math.NaN(), 1), modulus)
+	neqOpt(complex(math.Inf(1), 1), complex(1e300, 1), modulus, check.RelativeEpsilon(1))
+	neqOpt(complex(0, 1), complex(negZero, 1), modulus, strict)
+	neqOpt(complex64(complex(0, 1)), complex64(complex(negZero, 1)), modulus, strict)
+	eqOpt(complex64(complex(1, 1)), complex64(complex(1.05, 1)), modulus, strict, eps)
+
+	tol := check.IntegerTolerance()
+	neqOpt(1000, 1002, check.Epsilon(2))
+	eqOpt(1000, 1002, check.Epsilon(2), tol)
+	eqOpt([]byte{1, 2}, []byte{1, 3}, check.Epsilon(1), tol)
+	eqOpt([]int32{1, 2}, []int32{1, 3}, check.Epsilon(1), tol)
+	eqOpt([]byte{1, 2}, []int32{1, 3}, check.Epsilon(1), tol)
+	neqOpt([]byte{1, 2}, []int32{1, 4}, check.Epsilon(1), tol)
+	neqOpt([]byte{1, 2}, []byte{1, 3}, check.Epsilon(1))
+	eqOpt(int8(-1), uint64(1), check.Epsilon(2), tol)
+	eqOpt(uint(5), int16(3), check.Epsilon(2.5), tol)
+	neqOpt(uint(6), int16(3), check.Epsilon(2.5), tol)
+	neqOpt(int64(math.MinInt64), uint64(math.MaxUint64), check.Epsilon(1e18), tol)
+	eqOpt(int64(math.MinInt64), uint64(math.MaxUint64), check.Epsilon(1e20), tol)
+	eqOpt(int64(math.MinInt64), int64(math.MaxInt64), check.Epsilon(math.MaxUint64), tol)
+	neqOpt(int64(math.MinInt64), int64(math.MaxInt64), check.Epsilon(1<<63), tol)
+	eqOpt(int64(math.MinInt64), int64(math.MinInt64+3), check.Epsilon(3), tol)
+	eqOpt(uint64(math.MaxUint64), uint64(math.MaxUint64-3), check.Epsilon(3), tol)
+	eqOpt([]int{1, 2}, []uint{2, 3}, check.Epsilon(1), tol)
+	neqOpt(1, 2, check.Epsilon(0.9), tol)
+	neqOpt(1, 2, tol)
+
+	next := math.Nextafter(1, 2)
+	eqOpt(1.0, next, check.Epsilon(0), check.ULPs(1))
+	neqOpt(1.0, math.Nextafter(next, 2), check.Epsilon(0), check.ULPs(1))
+	eqOpt(1.0, math.Nextafter(next, 2), check.Epsilon(0), check.ULPs(2))
+	eqOpt(0.0, math.Copysign(0, -1), check.Epsilon(0), check.ULPs(1))
+	neqOpt(math.NaN(), 1.0, check.ULPs(math.MaxUint64))
+	next32 := math.Nextafter32(1, 2)
+	eqOpt(float32(1), next32, check.Epsilon(0), check.ULPs(1))
+	eqOpt(1, next32, check.Epsilon(0), check.ULPs(1))
+	eqOpt(complex64(1), complex(next32, 0), check.Epsilon(0), check.ULPs(1))
+	neqOpt(1, next, check.Epsilon(0), check.ULPs(0))
+
+	eqOpt(int64(1e12), 1e12+1000, check.Epsilon(0), check.RelativeEpsilon(1e-9))
+	eqOpt(complex(1e12, 1e12), complex(1e12+1000, 1e12), check.Epsilon(0),
+		check.RelativeEpsilon(1e-9))
+
+	type hidden struct {
+		Public  int
+		private int
+	}
+	eqOpt(hidden{1, 2}, hidden{1, 3}, check.IgnoreUnexported())
+	neqOpt(hidden{1, 2}, hidden{2, 2}, check.IgnoreUnexported())
+
+	type cached struct {
+		At    time.Time
+		cache int
+	}
+	utc := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	local := utc.In(time.FixedZone("", 3600))
+	neqOpt(cached{utc, 1}, cached{local, 1}, check.IgnoreEqualMethods())
+	eqOpt(cached{utc, 1}, cached{local, 1},
+		check.IgnoreEqualMethods(), check.IgnoreForeignUnexported())
+	neqOpt(cached{utc, 1}, cached{local, 2},
+		check.IgnoreEqualMethods(), check.IgnoreForeignUnexported())
+	eqOpt(cached{utc, 1}, cached{local, 2},
+		check.IgnoreEqualMethods(), check.IgnoreUnexported())
+
+	type user struct {
+		Name string
+		IDs  []int
+	}
+	eqOpt(
+		[]user{{"a", []int{1}}, {"b", []int{2}}},
+		[]user{{"a", []int{5}}, {"b", []int{2}}},
+		check.IgnorePaths("[0].IDs"),
+	)
+	neqOpt(
+		[]user{{"a", []int{1}}, {"b", []int{2}}},
+		[]user{{"a", []int{1}}, {"b", []int{5}}},
+		check.IgnorePaths("[0].IDs"),
+	)
+
+	eqOpt(
+		[]user{{"a", []int{1}}, {"b", []int{2}}},
+		[]user{{"a", []int{5}}, {"b", []int{6}}},
+		check.IgnorePaths("[*].IDs"),
+	)
+	neqOpt(
+		[]user{{"a", []int{1}}, {"b", []int{2}}},
+		[]user{{"a", []int{5}}, {"c", []int{6}}},
+		check.IgnorePaths("[*].IDs"),
+	)
+	type team struct {
+		Lead    user
+		Members map[string]user
+	}
+	eqOpt(
+		team{user{"a", nil}, map[string]user{"x]": {"b", []int{1}}}},
+		team{user{"z", nil}, map[string]user{"x]": {"b", []int{2}}}},
+		check.IgnorePaths("Lead.Name", "Members[*].IDs"),
+	)
+	neqOpt(
+		team{user{"a", nil}, map[string]user{"x]": {"b", []int{1}}}},
+		team{user{"a", nil}, map[string]user{"x]": {"c", []int{1}}}},
+		check.IgnorePaths("Members[*].IDs", "Lead[*]"),
+	)
+
+	// Paths of map entries that only exist on one side can be copied from the
+	// error message.
+	if d := check.Diff(map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1}, 0); d != `["b"]: 2 != <missing>` {
+		t.Error(d)
+	}
+	eqOpt(map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1},
+		check.IgnorePaths(`["b"]`))
+	eqOpt(map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1, "c": 3},
+		check.IgnorePaths(`["b"]`, `["c"]`))
+	neqOpt(map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1, "c": 3},
+		check.IgnorePaths(`["b"]`))
+	eqOpt(map[string]int{"a": 1, "b": 2}, map[string]float64{"a": 1},
+		check.IgnorePaths(`["b"]`))
+	neqOpt(map[string]int{"a": 1, "b": 2}, map[string]float64{"a": 2},
+		check.IgnorePaths(`["b"]`))
+	eqOpt(map[int]string{1: "a", 2: "b"}, map[uint]string{1: "a", 3: "c"},
+		check.IgnorePaths("[2]", "[0x3]"))
+	eqOpt(team{Members: map[string]user{"x": {}}}, team{Members: map[string]user{}},
+		check.IgnorePaths(".Members[*]"))
+
+	type event struct {
+		Name string
+		At   time.Time
+		At2  *time.Time
+	}
+	now := time.Now()
+	later := now.Add(time.Hour)
+	eqOpt(
+		event{"a", now, nil},
+		event{"a", later, nil},
+		check.IgnoreTypes(time.Time{}),
+	)
+	eqOpt(
+		event{"a", now, &now},
+		event{"a", later, &later},
+		check.IgnoreTypes(time.Time{}),
+	)
+	neqOpt(
+		event{"a", now, &now},
+		event{"a", later, nil},
+		check.IgnoreTypes(time.Time{}),
+	)
+	eqOpt(
+		event{"a", now, &now},
+		event{"a", later, nil},
+		check.IgnoreTypes(nil, time.Time{}, &time.Time{}),
+	)
+	neqOpt(
+		event{"a", now, nil},
+		event{"b", later, nil},
+		check.IgnoreTypes(time.Time{}),
+	)
+
+	eqOpt([]int{1, 2, 2, 3}, []int{2, 3, 2, 1}, check.Unordered())
+	eqOpt([3]int{1, 2, 3}, [3]int{3, 1, 2}, check.Unordered())
+	neqOpt([]int{1, 2, 2, 3}, []int{2, 3, 3, 1}, check.Unordered())
+	neqOpt([]int{1, 2}, []int{2, 1})
+	neqOpt([]int{1, 2, 3}, []int{2, 1}, check.Unordered())
+	eqOpt([]int{1, 2}, []float64{2, 1}, check.Unordered())
+	eqOpt([]byte{1, 2}, []byte{2, 1}, check.Unordered())
+	eqOpt([]int32{1, 2}, []int32{2, 1}, check.Unordered())
+	eqOpt([]int32{1, 2}, []byte{2, 1}, check.Unordered())
+	eqOpt("ab", []byte("ab"), check.Unordered())
+	neqOpt([]byte{1, 2}, []int32{2, 2}, check.Unordered())
+	// Near-equal map keys are paired so that the values match as well, no
+	// matter in which order the keys are visited.
+	for i := 0; i < 100; i++ {
+		eqOpt(map[float64]int{1.0: 1, 1.05: 2}, map[float32]int{1.05: 2, 1.0: 1},
+			check.Epsilon(0.1))
+		neqOpt(map[float64]int{1.0: 1, 1.05: 2}, map[float32]int{1.05: 2, 1.0: 2},
+			check.Epsilon(0.1))
+		d := check.DiffOpt(map[float64]int{1.0: 1, 1.05: 2},
+			map[float32]int{1.05: 2, 1.0: 1}, check.Epsilon(0.1))
+		if d != "" {
+			t.Fatal(d)
+		}
+	}
+	mapDiff := check.DiffOpt(map[int]string{1: "a", 2: "b"}, map[uint]string{1: "a", 2: "c", 3: "d"})
+	if mapDiff != `[2]: "b" != "c"
+"b"
+"c"
+ ^
+[0x3]: <missing> != "d"` {
+		t.Error(mapDiff)
+	}
+	eqOpt([]float64{1.05, 0.95}, []float64{1.0, 1.1},
+		check.Unordered(), check.Epsilon(0.1))
+	neqOpt([]float64{1.05, 0.95}, []float64{1.1, 1.2},
+		check.Unordered(), check.Epsilon(0.1))
+
+	var nilMap map[string]int
+	eqOpt(nilMap, map[string]int{})
+	neqOpt(nilMap, map[string]int{}, check.StrictNil())
+	eqOpt(nilMap, map[string]int{}, check.StrictNil(), check.NilEqualsEmpty())
+	neqOpt(nil, map[string]int{}, check.StrictNil())
+	neqOpt(nil, nilMap, check.StrictNil())
+	neqOpt(nil, []int{}, check.StrictNil())
+	neqOpt([]int(nil), []int{}, check.StrictNil())
+	eqOpt([]int(nil), []int(nil), check.StrictNil())
+	neqOpt([]byte(nil), []byte{}, check.StrictNil())
+	neqOpt([]rune(nil), []rune{}, check.StrictNil())
+	neqOpt([]byte(nil), []rune{}, check.StrictNil())
+	eqOpt([]byte(nil), []rune(nil), check.StrictNil())
+	eqOpt([]byte{}, []rune{}, check.StrictNil())
+	eqOpt(nil, nil, check.StrictNil())
+	neqOpt([]interface{}{nil}, []interface{}{[]int{}}, check.StrictNil())
+	eqOpt([]interface{}{nil}, []interface{}{nil}, check.StrictNil())
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("options passed as messages must panic")
+			}
+		}()
+		check.Eq(t, 1, 1, check.Epsilon(1))
+	}()
+
+	var tt mockTester
+	check.EqOpt(&tt, 1, 2, check.Message("input ", 5))
+	if tt.err != "input 5: 1 != 2" {
+		t.Error(tt.err)
+	}
+
+	if !check.EqualOpt([]int{1, 2}, []int{2, 1}, check.Unordered()) {
+		t.Error("EqualOpt should use the options")
+	}
+	if d := check.DiffOpt(1.0, 1.5, check.Epsilon(0.1)); d != "1 != 1.5" {
+		t.Error(d)
+	}
+
+	d := check.DiffOpt(
+		[]interface{}{3, "x", 1, 1},
+		[]int{1, 2, 3, 2},
+		check.Unordered(),
+	)
+	if d != `unordered elements differ
+missing: 2, 2
+extra: "x", 1` {
+		t.Error(d)
+	}
+	d = check.DiffOpt([]int{1, 2}, []int{2}, check.Unordered())
+	if d != "unordered elements differ\nextra: 1" {
+		t.Error(d)
+	}
+
+	var fatal mockFatalTester
+	check.MustEqOpt(&fatal, 1.0, 1.5, check.Epsilon(0.5))
+	check.MustNeqOpt(&fatal, 1.0, 1.5, check.Epsilon(0.1))
+	if fatal.fatal != "" {
+		t.Error(fatal.fatal)
+	}
+	check.MustEqOpt(&fatal, 1.0, 1.5, check.Epsilon(0.1))
+	if fatal.fatal != "1 != 1.5" {
+		t.Error(fatal.fatal)
+	}
+}
+
+func TestCompareFieldsByName(t *testing.T) {
+	type userDTO struct {
+		ID    string
+		Name  string
+		Age   int64
+		Tags  []string
+		cache int
+	}
+	type address struct{ City string }
+	type user struct {
+		ID      []byte
+		Name    string
+		Age     uint8
+		Tags    []string
+		secret  string
+		Address address
+	}
+
+	dto := userDTO{ID: "1", Name: "Ann", Age: 42, Tags: []string{"a"}, cache: 5}
+	u := user{ID: []byte("1"), Name: "Ann", Age: 42, Tags: []string{"a"}, secret: "x"}
+
+	var tt mockTester
+	check.EqOpt(&tt, dto, u, check.CompareFieldsByName())
+	if tt.err != ".Address: <missing> != check_test.address{City:\"\"}" {
+		t.Error(tt.err)
+	}
+
+	tt.err = ""
+	check.EqOpt(&tt, dto, u,
+		check.CompareFieldsByName(),
+		check.IgnorePaths(".Address"),
+	)
+	if tt.err != "" {
+		t.Error(tt.err)
+	}
+
+	u.Age = 43
+	if check.EqualOpt(dto, u, check.CompareFieldsByName(), check.IgnorePaths(".Address")) {
+		t.Error("different ages must differ")
+	}
+	if check.EqualOpt(dto, u) {
+		t.Error("different struct types must differ by default")
+	}
+	d := check.DiffOpt(
+		[]userDTO{dto},
+		[]user{u},
+		check.CompareFieldsByName(),
+	)
+	if d != "[0].Age: 42 != 0x2b\n[0].Address: <missing> != check_test.address{City:\"\"}" {
+		t.Error(d)
+	}
+
+	// Pointers to structs of different types are compared by the structs.
+	type addrDTO struct{ City string }
+	type addr struct{ City string }
+	type personDTO struct{ Home, Work *addrDTO }
+	type person struct{ Home, Work *addr }
+	byName := check.CompareFieldsByName()
+	if !check.EqualOpt(personDTO{Home: &addrDTO{"x"}}, person{Home: &addr{"x"}}, byName) {
+		t.Error("pointers to equal structs must be equal")
+	}
+	if !check.EqualOpt(&personDTO{}, &person{}, byName) {
+		t.Error("nil pointers must be equal")
+	}
+	d = check.DiffOpt(
+		personDTO{Home: &addrDTO{"x"}, Work: &addrDTO{"y"}},
+		person{Home: &addr{"z"}},
+		byName,
+	)
+	if d != `.Home.City: "x" != "z"
+"x"
+"z"
+ ^
+.Work: &check_test.addrDTO{City:"y"} != (*check_test.addr)(nil)` {
+		t.Error(d)
+	}
+
+	type nodeDTO struct{ Next *nodeDTO }
+	type node struct{ Next *node }
+	cycleDTO := &nodeDTO{}
+	cycleDTO.Next = cycleDTO
+	cycle := &node{}
+	cycle.Next = cycle
+	if !check.EqualOpt(cycleDTO, cycle, byName) {
+		t.Error("equal cycles must be equal")
+	}
+}
+
+func TestCompareFieldsByNamePromotesEmbeddedFields(t *testing.T) {
+	type Base struct {
+		ID      int
+		Created string
+	}
+	type dto struct {
+		ID   int
+		Name string
+	}
+	type domain struct {
+		Base `check:"-"`
+		Name string
+	}
+	type entity struct {
+		*Base
+		Name string
+	}
+	type audit struct{ Created string }
+	type ambiguous struct {
+		Base
+		audit
+		Name string
+	}
+	byName := check.CompareFieldsByName()
+
+	check.EqOpt(t, dto{1, "a"}, entity{&Base{ID: 1}, "a"}, byName,
+		check.IgnorePaths("Created"))
+	check.NeqOpt(t, dto{1, "a"}, entity{&Base{ID: 2}, "a"}, byName,
+		check.IgnorePaths("Created"))
+	check.EqOpt(t, dto{1, "a"}, domain{Base{ID: 2}, "a"}, byName)
+	check.EqOpt(t, dto{1, "a"}, ambiguous{Base{ID: 1}, audit{"x"}, "a"}, byName)
+
+	d := check.DiffOpt(dto{1, "a"}, entity{nil, "a"}, byName)
+	if d != ".ID: 1 != <missing>" {
+		t.Error(d)
+	}
+	d = check.DiffOpt(entity{&Base{1, "now"}, "a"}, dto{1, "a"}, byName)
+	if d != `.Created: "now" != <missing>` {
+		t.Error(d)
+	}
+}
+
+func TestCompareStructsToMaps(t *testing.T) {
+	type item struct {
+		SKU   string  `json:"sku"`
+		Price float64 `json:"price,omitempty"`
+		Note  string  `json:"-"`
+	}
+	type order struct {
+		ID    int64 `json:"id"`
+		Items []item
+		Paid  bool
+		note  string
+	}
+	o := order{
+		ID:    7,
+		Items: []item{{"a", 1.5, "x"}, {"b", 2, "y"}},
+		Paid:  true,
+		note:  "ignored",
+	}
+	var decoded interface{}
+	err := json.Unmarshal([]byte(`{
+		"id": 7,
+		"Items": [{"sku": "a", "price": 1.5}, {"sku": "b", "price": 2}],
+		"Paid": true
+	}`), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tt mockTester
+	check.EqOpt(&tt, o, decoded, check.CompareStructsToMaps(), check.UseJSONTags())
+	check.EqOpt(&tt, decoded, o, check.CompareStructsToMaps(), check.UseJSONTags())
+	check.EqOpt(&tt, o.Items[0], map[string]interface{}{
+		"SKU":   "a",
+		"Price": 1.5,
+		"Note":  "x",
+	}, check.CompareStructsToMaps())
+	if tt.err != "" {
+		t.Error(tt.err)
+	}
+
+	check.EqOpt(&tt, o, decoded, check.CompareStructsToMaps())
+	if tt.err != `.ID: 7 != <missing>
+.Items[0].SKU: "a" != <missing>
+.Items[0].Price: 1.5 != <missing>
+.Items[0].Note: "x" != <missing>
+.Items[0]["price"]: <missing> != 1.5
+.Items[0]["sku"]: <missing> != "a"
+.Items[1].SKU: "b" != <missing>
+.Items[1].Price: 2 != <missing>
+.Items[1].Note: "y" != <missing>
+.Items[1]["price"]: <missing> != 2
+.Items[1]["sku"]: <missing> != "b"
+["id"]: <missing> != 7` {
+		t.Error(tt.err)
+	}
+
+	tt.err = ""
+	check.EqOpt(&tt, map[string]interface{}{"sku": "a", "price": 2},
+		o.Items[0], check.CompareStructsToMaps(), check.UseJSONTags())
+	if tt.err != `.Price: 2 != 1.5` {
+		t.Error(tt.err)
+	}
+
+	if check.EqualOpt(o.Items[0], map[string]interface{}{"sku": "a"}) {
+		t.Error("structs and maps must differ by default")
+	}
+
+	// Embedded structs are flattened like encoding/json does it.
+	type Base struct {
+		ID int `json:"id"`
+	}
+	type Meta struct{ Version int }
+	type doc struct {
+		Base
+		*Meta
+		Owner Base `json:"owner"`
+		Title string
+	}
+	for _, d := range []doc{
+		{Base{1}, &Meta{2}, Base{3}, "x"},
+		{Base{1}, nil, Base{3}, "x"},
+	} {
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var roundTrip map[string]interface{}
+		if err := json.Unmarshal(data, &roundTrip); err != nil {
+			t.Fatal(err)
+		}
+		check.EqOpt(t, d, roundTrip, check.CompareStructsToMaps(), check.UseJSONTags())
+	}
+	diff := check.DiffOpt(
+		doc{Base{1}, nil, Base{3}, "x"},
+		map[string]interface{}{"id": 1, "Version": 2, "owner": map[string]int{"id": 3}, "Title": "x"},
+		check.CompareStructsToMaps(), check.UseJSONTags(),
+	)
+	if diff != ".Version: <missing> != 2" {
+		t.Error(diff)
+	}
+
+	// Pointers to structs are compared by the structs they point to.
+	type addr struct{ City string }
+	type home struct{ P *addr }
+	toMaps := check.CompareStructsToMaps()
+	check.EqOpt(t, home{&addr{"x"}}, map[string]interface{}{
+		"P": map[string]interface{}{"City": "x"},
+	}, toMaps)
+	check.EqOpt(t, map[string]interface{}{"City": "x"}, &addr{"x"}, toMaps)
+	check.EqOpt(t, &addr{"x"}, map[string]interface{}{"City": "x"}, toMaps)
+	check.NeqOpt(t, &addr{"x"}, map[string]interface{}{"City": "y"}, toMaps)
+	check.EqOpt(t, (*addr)(nil), map[string]interface{}(nil), toMaps)
+	check.NeqOpt(t, (*addr)(nil), map[string]interface{}{}, toMaps)
+	check.NeqOpt(t, home{nil}, map[string]interface{}{
+		"P": map[string]interface{}{"City": "x"},
+	}, toMaps)
+}
+
+func TestStructTags(t *testing.T) {
+	type measurement struct {
+		ID     int       `check:"-"`
+		Value  float64   `check:"eps=1e-3"`
+		Tags   []string  `check:"unordered"`
+		Series []float64 `check:"eps=0.1, unordered"`
+		Exact  float64
+	}
+	m := measurement{
+		ID:     1,
+		Value:  1.5,
+		Tags:   []string{"a", "b"},
+		Series: []float64{1, 2},
+		Exact:  3,
+	}
+	same := measurement{
+		ID:     2,
+		Value:  1.5005,
+		Tags:   []string{"b", "a"},
+		Series: []float64{2.05, 0.95},
+		Exact:  3,
+	}
+	check.Eq(t, m, same)
+	type packet struct {
+		Bytes []byte  `check:"unordered"`
+		Runes []int32 `check:"unordered"`
+	}
+	check.Eq(t, packet{[]byte{1, 2}, []int32{3, 4}}, packet{[]byte{2, 1}, []int32{4, 3}})
+
+	different := same
+	different.Value = 1.502
+	different.Tags = []string{"a", "a"}
+	different.Exact = 3.001
+	d := check.Diff(m, different, 1e-6)
+	if d != `.Value: 1.5 != 1.502
+.Tags: unordered elements differ
+missing: "a"
+extra: "b"
+.Exact: 3 != 3.001` {
+		t.Error(d)
+	}
+
+	// Tags also apply when comparing to other structs and to maps.
+	type dto struct {
+		Value  float64
+		Tags   []string
+		Series []float64
+		Exact  float64
+	}
+	check.EqOpt(t, m, dto{1.5005, []string{"b", "a"}, []float64{2, 1}, 3},
+		check.CompareFieldsByName())
+	check.EqOpt(t, map[string]interface{}{
+		"ID":     5,
+		"Value":  1.5005,
+		"Tags":   []string{"b", "a"},
+		"Series": []float64{2, 1},
+		"Exact":  3,
+	}, m, check.CompareStructsToMaps())
+	check.EqOpt(t, map[string]interface{}{
+		"Value":  1.5005,
+		"Tags":   []string{"b", "a"},
+		"Series": []float64{2, 1},
+		"Exact":  3,
+	}, m, check.CompareStructsToMaps())
+
+	type empty struct {
+		X int `check:""`
+		Y int `check:"unordered, "`
+	}
+	check.Eq(t, empty{1, 2}, empty{1, 2})
+	check.Neq(t, empty{1, 2}, empty{2, 2})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("invalid tags must panic")
+			}
+		}()
+		type invalid struct {
+			X int `check:"ordered"`
+		}
+		check.Equal(invalid{}, invalid{}, 0)
+	}()
+}
+
+func TestMatchers(t *testing.T) {
+	type user struct {
+		ID   string
+		Name string
+		Age  int
+		Tags []string
+		Meta interface{}
+	}
+	u := user{ID: "id-42", Name: "Ann", Age: 42, Tags: []string{"a", "b"}}
+	check.EqOpt(t, u, map[string]interface{}{
+		"ID":   check.MatchRegexp("^id-"),
+		"Name": check.NotZero(),
+		"Age":  check.Between(18, 99.5),
+		"Tags": check.Len(2),
+		"Meta": check.Any(),
+	}, check.CompareStructsToMaps())
+	check.Eq(t, u, user{"id-42", "Ann", 42, []string{"a", "b"}, check.Any()})
+
+	check.Eq(t, nil, check.Any())
+	check.Eq(t, check.Any(), 5)
+	check.Eq(t, []interface{}{1, "x"}, []interface{}{check.NotZero(), check.Len(1)})
+	check.Eq(t, []int{1, 2}, check.Len(2))
+	check.Eq(t, []byte("abc"), check.MatchRegexp("b"))
+	check.Eq(t, []int(nil), check.Len(0))
+	check.Eq(t, 5.0000001, check.Between(1, 5))
+	check.Eq(t, uint64(math.MaxUint64), check.Between(-1, uint64(math.MaxUint64)))
+	check.Neq(t, nil, check.NotZero())
+	check.Neq(t, 0, check.NotZero())
+	check.Neq(t, struct{ A, B int }{}, check.NotZero())
+	check.Neq(t, []int{}, check.NotZero())
+	check.NeqOpt(t, nil, check.Len(0), check.StrictNil())
+	check.Neq(t, 5.1, check.Between(1, 5))
+	check.Neq(t, -1, check.Between(uint8(0), uint8(5)))
+	check.Neq(t, math.NaN(), check.Between(1, 5))
+	check.Neq(t, "5", check.Between(1, 5))
+	check.Neq(t, "ID-1", check.MatchRegexp("^id-"))
+	check.Neq(t, 5, check.MatchRegexp("5"))
+	check.Neq(t, "abc", check.Len(2))
+	check.Neq(t, 2, check.Len(2))
+
+	d := check.Diff(
+		[]interface{}{1, "x", 3},
+		[]interface{}{check.Between(2, 3), check.MatchRegexp("y"), 3},
+		0,
+	)
+	if d != `[0]: 1 != check.Between(2, 3)
+[1]: "x" != check.MatchRegexp("y")` {
+		t.Error(d)
+	}
+	if d := check.Diff(0, check.NotZero(), 0); d != "0 != check.NotZero()" {
+		t.Error(d)
+	}
+}
+
+func TestComparers(t *testing.T) {
+	type ident string
+	check.RegisterComparer(func(a, b ident) bool {
+		return strings.EqualFold(string(a), string(b))
+	})
+	type vec struct{ X, Y float64 }
+	near := func(a, b vec) bool {
+		return math.Hypot(a.X-b.X, a.Y-b.Y) < 0.1
+	}
+
+	check.Eq(t, ident("ABC"), ident("abc"))
+	check.Neq(t, ident("ABC"), ident("abd"))
+	check.Eq(t, map[ident][]ident{"a": {"X"}}, map[ident][]ident{"a": {"x"}})
+	check.Eq(t, []interface{}{ident("A")}, []ident{"a"})
+	// Only values of the registered type use the comparer.
+	check.Neq(t, ident("A"), "a")
+
+	check.Neq(t, vec{0, 0}, vec{0.05, 0})
+	check.EqOpt(t, vec{0, 0}, vec{0.05, 0}, check.Comparer(near))
+	check.EqOpt(t,
+		struct{ Path []vec }{[]vec{{1, 1}, {2, 2}}},
+		struct{ Path []vec }{[]vec{{1, 1.05}, {2.05, 2}}},
+		check.Comparer(near),
+	)
+	check.NeqOpt(t, vec{0, 0}, vec{0.5, 0}, check.Comparer(near))
+	check.NeqOpt(t, ident("A"), ident("a"), check.Comparer(func(a, b ident) bool {
+		return a == b
+	}))
+
+	// Comparers are used inside of unexported fields as well.
+	type idents struct {
+		byName map[string]ident
+	}
+	check.Eq(t,
+		idents{map[string]ident{"a": "X"}},
+		idents{map[string]ident{"a": "x"}},
+	)
+
+	// Comparers take precedence over Equal methods.
+	sameDay := func(a, b time.Time) bool {
+		return a.YearDay() == b.YearDay() && a.Year() == b.Year()
+	}
+	noon := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+	check.EqOpt(t, noon, noon.Add(time.Hour), check.Comparer(sameDay))
+
+	for _, f := range []interface{}{
+		nil,
+		5,
+		func(a, b int) {},
+		func(a int, b uint) bool { return true },
+		func(a ...int) bool { return true },
+		(func(a, b int) bool)(nil),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Comparer(%T) should panic", f)
+				}
+			}()
+			check.Comparer(f)
+		}()
+	}
+}
+
+func TestTransform(t *testing.T) {
+	trim := check.Transform(strings.TrimSpace)
+	check.EqOpt(t, " a ", "a", trim)
+	check.EqOpt(t, []string{"a\n", " b"}, []interface{}{"a", "b "}, trim)
+	check.NeqOpt(t, " a ", "b", trim)
+
+	type record struct {
+		Name  string
+		Count string
+		IDs   []int
+	}
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	sorted := func(s []int) []int {
+		s = append([]int(nil), s...)
+		sort.Ints(s)
+		return s
+	}
+	check.EqOpt(t,
+		record{"Ann", "5", []int{3, 1, 2}},
+		record{"ann", "5", []int{1, 2, 3}},
+		check.Transform(strings.ToLower),
+		check.Transform(sorted),
+	)
+	check.EqOpt(t, map[string]string{"x": "5"}, map[string]int{"x": 5},
+		check.Transform(atoi))
+	check.NeqOpt(t, "5", 6, check.Transform(atoi))
+
+	// Transformers are not applied inside their own results, otherwise these
+	// would never stop.
+	fields := check.Transform(strings.Fields)
+	check.EqOpt(t, "a  b", " a b", fields)
+	check.NeqOpt(t, "a b", "a c", fields)
+	check.EqOpt(t, map[string]string{"x": "a  b"}, map[string]string{"x": "a b"}, fields)
+	asInterface := check.Transform(func(s string) interface{} { return s })
+	check.EqOpt(t, "a", "a", asInterface)
+	check.NeqOpt(t, "a", "b", asInterface)
+
+	check.Neq(t, "a", "A", "Transform only applies if passed as an option")
+
+	d := check.DiffOpt(
+		record{"Ann", "5", []int{3, 1, 2}},
+		record{"Bob", "5", []int{1, 2, 4}},
+		check.Transform(strings.ToLower),
+		check.Transform(sorted),
+	)
+	if d != `.Name: "Ann" != "Bob", transformed:
+.Name: "ann" != "bob"
+"ann"
+"bob"
+ ^
+.IDs: []int{3, 1, 2} != []int{1, 2, 4}, transformed:
+.IDs[2]: 3 != 4` {
+		t.Error(d)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Transform must panic for invalid functions")
+			}
+		}()
+		check.Transform(func(a, b int) int { return a + b })
+	}()
 }
 
 func TestHelperFunctionIsDeclared(t *testing.T) {
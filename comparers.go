@@ -0,0 +1,75 @@
+package check
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	registeredComparersMutex sync.RWMutex
+	registeredComparers      = make(map[reflect.Type]reflect.Value)
+)
+
+// RegisterComparer makes all checks compare values of type T with f, which
+// must be a function of the form
+//
+//	func(a, b T) bool
+//
+// f is used for values of type T wherever they occur, e.g. inside of slices,
+// maps and structs, and it takes precedence over Equal and Cmp methods of T. A
+// Comparer option for the same type overrides the registered function for a
+// single check. Registering a second function for T replaces the first one.
+// RegisterComparer panics if f does not have the right form.
+//
+// RegisterComparer is typically called from an init function or from TestMain.
+// It is safe to call it concurrently with running checks.
+func RegisterComparer(f interface{}) {
+	t, fv := comparerFunc("RegisterComparer", f)
+	registeredComparersMutex.Lock()
+	defer registeredComparersMutex.Unlock()
+	registeredComparers[t] = fv
+}
+
+// comparerFunc checks that f has the form func(T, T) bool and returns T and f.
+// It panics with a message that mentions the caller if this is not the case.
+func comparerFunc(caller string, f interface{}) (reflect.Type, reflect.Value) {
+	fv := reflect.ValueOf(f)
+	if f == nil || fv.Kind() != reflect.Func || fv.IsNil() {
+		panic(fmt.Sprintf("check: %s needs a func(T, T) bool but got %#v", caller, f))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 || ft.In(0) != ft.In(1) || ft.IsVariadic() ||
+		ft.NumOut() != 1 || ft.Out(0).Kind() != reflect.Bool {
+		panic(fmt.Sprintf("check: %s needs a func(T, T) bool but got a %v", caller, ft))
+	}
+	return ft.In(0), fv
+}
+
+// customComparer returns the function that was set with a Comparer option or
+// RegisterComparer for type t, if there is one.
+func (c *comparer) customComparer(t reflect.Type) (reflect.Value, bool) {
+	if f, ok := c.comparers[t]; ok {
+		return f, true
+	}
+	registeredComparersMutex.RLock()
+	defer registeredComparersMutex.RUnlock()
+	f, ok := registeredComparers[t]
+	return f, ok
+}
+
+// equalByComparer compares v1 and v2, which have the same type, with a custom
+// comparer function. It returns false as its second result if there is none or
+// if it cannot be called with these values.
+func (c *comparer) equalByComparer(v1, v2 reflect.Value) (equal, ok bool) {
+	f, ok := c.customComparer(v1.Type())
+	if !ok {
+		return false, false
+	}
+	v1, ok1 := interfaceable(v1)
+	v2, ok2 := interfaceable(v2)
+	if !ok1 || !ok2 {
+		return false, false
+	}
+	return f.Call([]reflect.Value{v1, v2})[0].Bool(), true
+}
@@ -0,0 +1,388 @@
+package check
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// shortStringLen is the number of runes up to which a single-line string is
+// considered short enough to be printed in full in a failure message.
+const shortStringLen = 60
+
+// caretContext is the number of runes that are shown before and after the first
+// differing rune of two long single-line strings.
+const caretContext = 30
+
+// lineContext is the number of unchanged lines around changed lines in a line
+// diff.
+const lineContext = 3
+
+// maxLCSCells limits the size of the table that is used to compute the longest
+// common subsequence of two sets of lines. Larger inputs are reported as one
+// big change.
+const maxLCSCells = 1 << 22
+
+// hexDumpWidth is the number of bytes per line in a hex dump.
+const hexDumpWidth = 16
+
+// maxHexDumpRows is the number of differing lines after which a hex dump is cut
+// off.
+const maxHexDumpRows = 8
+
+// stringDifference describes the mismatch of the string-like values v1 and v2
+// whose contents are b1 and b2.
+func stringDifference(path string, v1, v2 reflect.Value, b1, b2 []byte) difference {
+	d := difference{path: path, a: v1, b: v2}
+	if !isText(b1) || !isText(b2) {
+		d.text = hexDiff(b1, b2)
+		return d
+	}
+	s1, s2 := string(b1), string(b2)
+	if strings.Contains(s1, "\n") || strings.Contains(s2, "\n") {
+		d.text = "strings differ:\n" + lineDiff(s1, s2)
+		return d
+	}
+	r1, r2 := []rune(s1), []rune(s2)
+	i := 0
+	for i < len(r1) && i < len(r2) && r1[i] == r2[i] {
+		i++
+	}
+	if len(r1) <= shortStringLen && len(r2) <= shortStringLen {
+		d.text = formatValue(v1) + " != " + formatValue(v2) + "\n"
+	} else {
+		d.text = fmt.Sprintf("strings differ at rune %d:\n", i)
+	}
+	d.text += caretLines(r1, r2, i)
+	return d
+}
+
+// caretLines quotes r1 and r2 around index i, the index of their first
+// differing rune, and puts a caret under that rune in a third line.
+func caretLines(r1, r2 []rune, i int) string {
+	start := i - caretContext
+	if start < 0 {
+		start = 0
+	}
+	var caretCol int
+	quote := func(r []rune) string {
+		end := i + caretContext
+		if end > len(r) {
+			end = len(r)
+		}
+		var head, tail string
+		if start > 0 {
+			head = "..."
+		}
+		if end < len(r) {
+			tail = "..."
+		}
+		// The caret goes right after the quoted common prefix, minus its
+		// closing quote. Both lines share this prefix.
+		caretCol = utf8.RuneCountInString(head+strconv.Quote(string(r[start:i]))) - 1
+		return head + strconv.Quote(string(r[start:end])) + tail
+	}
+	return quote(r1) + "\n" + quote(r2) + "\n" + strings.Repeat(" ", caretCol) + "^"
+}
+
+// lineOp is a single line in a line diff. kind is ' ' for lines that are in
+// both texts, '-' for lines only in the first and '+' for lines only in the
+// second text. aLine and bLine are the 1-based line numbers in the two texts,
+// they are 0 if the line does not exist in that text.
+type lineOp struct {
+	kind         byte
+	text         string
+	aLine, bLine int
+}
+
+// lineDiff returns a unified diff of the lines of a and b, annotated with line
+// numbers.
+func lineDiff(a, b string) string {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+	ops := diffLines(aLines, bLines)
+
+	width := len(strconv.Itoa(len(aLines)))
+	if w := len(strconv.Itoa(len(bLines))); w > width {
+		width = w
+	}
+	lineNumber := func(n int) string {
+		if n == 0 {
+			return strings.Repeat(" ", width)
+		}
+		return fmt.Sprintf("%*d", width, n)
+	}
+
+	lines := []string{"--- a", "+++ b"}
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk around it until there are
+		// more than 2*lineContext unchanged lines in a row.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops) && i-last <= 2*lineContext; i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			}
+		}
+		from := first - lineContext
+		if from < start {
+			from = start
+		}
+		to := last + lineContext + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		var aStart, aCount, bStart, bCount int
+		for _, op := range ops[from:to] {
+			if op.aLine != 0 {
+				if aCount == 0 {
+					aStart = op.aLine
+				}
+				aCount++
+			}
+			if op.bLine != 0 {
+				if bCount == 0 {
+					bStart = op.bLine
+				}
+				bCount++
+			}
+		}
+		if aCount == 0 {
+			aStart = previousLine(ops[:from], func(op lineOp) int { return op.aLine })
+		}
+		if bCount == 0 {
+			bStart = previousLine(ops[:from], func(op lineOp) int { return op.bLine })
+		}
+		lines = append(lines, fmt.Sprintf(
+			"@@ -%d,%d +%d,%d @@", aStart, aCount, bStart, bCount,
+		))
+		for _, op := range ops[from:to] {
+			line := fmt.Sprintf(
+				"%c%s %s |", op.kind, lineNumber(op.aLine), lineNumber(op.bLine),
+			)
+			if op.text != "" {
+				line += " " + op.text
+			}
+			lines = append(lines, line)
+		}
+		start = to
+	}
+	return strings.Join(lines, "\n")
+}
+
+// previousLine returns the last non-zero line number in ops, as selected by
+// line, or 0 if there is none.
+func previousLine(ops []lineOp, line func(lineOp) int) int {
+	for i := len(ops) - 1; i >= 0; i-- {
+		if n := line(ops[i]); n != 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// diffLines computes the operations that turn the lines a into the lines b,
+// based on their longest common subsequence.
+func diffLines(a, b []string) []lineOp {
+	var ops []lineOp
+	same := func(i, j int) {
+		ops = append(ops, lineOp{kind: ' ', text: a[i], aLine: i + 1, bLine: j + 1})
+	}
+	removed := func(i int) {
+		ops = append(ops, lineOp{kind: '-', text: a[i], aLine: i + 1})
+	}
+	added := func(j int) {
+		ops = append(ops, lineOp{kind: '+', text: b[j], bLine: j + 1})
+	}
+
+	// Common prefix and suffix need not go into the LCS table.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for i := 0; i < prefix; i++ {
+		same(i, i)
+	}
+
+	aEnd, bEnd := len(a)-suffix, len(b)-suffix
+	n, m := aEnd-prefix, bEnd-prefix
+	if n*m > maxLCSCells {
+		for i := prefix; i < aEnd; i++ {
+			removed(i)
+		}
+		for j := prefix; j < bEnd; j++ {
+			added(j)
+		}
+	} else {
+		// lcs[i*(m+1)+j] is the length of the longest common subsequence of
+		// the middle parts of a[i:] and b[j:].
+		lcs := make([]int32, (n+1)*(m+1))
+		at := func(i, j int) int32 { return lcs[i*(m+1)+j] }
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if a[prefix+i] == b[prefix+j] {
+					lcs[i*(m+1)+j] = at(i+1, j+1) + 1
+				} else if at(i+1, j) >= at(i, j+1) {
+					lcs[i*(m+1)+j] = at(i+1, j)
+				} else {
+					lcs[i*(m+1)+j] = at(i, j+1)
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && a[prefix+i] == b[prefix+j]:
+				same(prefix+i, prefix+j)
+				i++
+				j++
+			case j == m || i < n && at(i+1, j) >= at(i, j+1):
+				removed(prefix + i)
+				i++
+			default:
+				added(prefix + j)
+				j++
+			}
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		same(aEnd+k, bEnd+k)
+	}
+	return ops
+}
+
+// isText reports whether b is valid UTF-8 without control characters other
+// than white space. Anything else is treated as binary data.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// hexDiff describes the differences of the binary data a and b. It starts with
+// a summary line and then shows a hex dump of the lines that contain
+// differences, together with one line of context around them. Lines that are
+// the same in a and b are prefixed with a space, lines that differ are shown
+// twice, prefixed with - for a and + for b.
+func hexDiff(a, b []byte) string {
+	common, longest := len(a), len(b)
+	if common > longest {
+		common, longest = longest, common
+	}
+	differing := longest - common
+	first := -1
+	for i := 0; i < common; i++ {
+		if a[i] != b[i] {
+			differing++
+			if first == -1 {
+				first = i
+			}
+		}
+	}
+	if first == -1 {
+		first = common
+	}
+
+	lines := []string{fmt.Sprintf(
+		"binary data differs: length %d != %d, %d differing bytes, first at offset %#x",
+		len(a), len(b), differing, first,
+	)}
+
+	rowCount := (longest + hexDumpWidth - 1) / hexDumpWidth
+	row := func(data []byte, r int) []byte {
+		return hexRow(data, r*hexDumpWidth, (r+1)*hexDumpWidth)
+	}
+	show := make(map[int]bool)
+	for r, shown := first/hexDumpWidth, 0; r < rowCount && shown < maxHexDumpRows; r++ {
+		if !bytes.Equal(row(a, r), row(b, r)) {
+			show[r-1] = true
+			show[r] = true
+			show[r+1] = true
+			shown++
+		}
+	}
+	last := -1
+	for r := 0; r < rowCount; r++ {
+		if !show[r] {
+			continue
+		}
+		if r != last+1 {
+			lines = append(lines, "...")
+		}
+		last = r
+		rowA, rowB := row(a, r), row(b, r)
+		offset := r * hexDumpWidth
+		if bytes.Equal(rowA, rowB) {
+			lines = append(lines, " "+hexLine(offset, rowA))
+			continue
+		}
+		if rowA != nil {
+			lines = append(lines, "-"+hexLine(offset, rowA))
+		}
+		if rowB != nil {
+			lines = append(lines, "+"+hexLine(offset, rowB))
+		}
+	}
+	if last+1 < rowCount {
+		lines = append(lines, "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hexRow returns b[from:to], cut off at the end of b. It returns nil if from is
+// past the end of b.
+func hexRow(b []byte, from, to int) []byte {
+	if from >= len(b) {
+		return nil
+	}
+	if to > len(b) {
+		to = len(b)
+	}
+	return b[from:to]
+}
+
+// hexLine formats one line of a hex dump in the style of hexdump -C.
+func hexLine(offset int, row []byte) string {
+	line := fmt.Sprintf("%08x ", offset)
+	for i := 0; i < hexDumpWidth; i++ {
+		if i%8 == 0 {
+			line += " "
+		}
+		if i < len(row) {
+			line += fmt.Sprintf("%02x ", row[i])
+		} else {
+			line += "   "
+		}
+	}
+	line += " |"
+	for _, c := range row {
+		if c < 0x20 || c > 0x7E {
+			c = '.'
+		}
+		line += string(c)
+	}
+	return line + "|"
+}
@@ -0,0 +1,140 @@
+package check
+
+import (
+	"reflect"
+	"strings"
+)
+
+// namedField is an exported struct field, possibly promoted from an embedded
+// struct, together with the name that it is matched by.
+type namedField struct {
+	name string
+	// field.Index is the sequence of indices that leads from the outer struct
+	// to the field, see fieldByIndex.
+	field reflect.StructField
+	// tag holds the settings of the field's check tag. It is ignored if one
+	// of the embedded structs that it is promoted from is ignored.
+	tag fieldTag
+}
+
+// namedFields returns the fields of the struct type t that are matched by name
+// when comparing structs of different types or structs and maps. If useJSON is
+// set, fields are named by their json tags.
+//
+// The fields of embedded structs are promoted like encoding/json does it:
+// their exported fields are listed instead of the embedded struct itself. A
+// field hides fields with the same name that are embedded deeper. If there
+// are multiple fields with the same name at the same depth, the one with a
+// json tag wins, otherwise the name is ambiguous and none of them is listed.
+func namedFields(t reflect.Type, useJSON bool) []namedField {
+	type candidate struct {
+		namedField
+		depth  int
+		tagged bool
+	}
+	var candidates []candidate
+	var walk func(t reflect.Type, index []int, depth int, ignore bool, seen map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, depth int, ignore bool, seen map[reflect.Type]bool) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			f.Index = append(append([]int(nil), index...), i)
+			name, tagged, ok := fieldName(f, useJSON)
+			if !ok {
+				continue
+			}
+			tag := parseFieldTag(f)
+			tag.ignore = tag.ignore || ignore
+			if f.Anonymous && !tagged {
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					// Embedded pointers might lead to the same type again.
+					if !seen[ft] {
+						seen[ft] = true
+						walk(ft, f.Index, depth+1, tag.ignore, seen)
+						delete(seen, ft)
+					}
+					continue
+				}
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			candidates = append(candidates, candidate{
+				namedField: namedField{name: name, field: f, tag: tag},
+				depth:      depth,
+				tagged:     tagged,
+			})
+		}
+	}
+	walk(t, nil, 0, false, map[reflect.Type]bool{t: true})
+
+	// Find the dominant field for every name.
+	byName := make(map[string][]int)
+	for i, c := range candidates {
+		byName[c.name] = append(byName[c.name], i)
+	}
+	dominant := make(map[int]bool)
+	for _, indices := range byName {
+		var shallowest, tagged []int
+		for _, i := range indices {
+			c := candidates[i]
+			if len(shallowest) > 0 && c.depth > candidates[shallowest[0]].depth {
+				continue
+			}
+			if len(shallowest) > 0 && c.depth < candidates[shallowest[0]].depth {
+				shallowest, tagged = nil, nil
+			}
+			shallowest = append(shallowest, i)
+			if c.tagged {
+				tagged = append(tagged, i)
+			}
+		}
+		if len(shallowest) == 1 {
+			dominant[shallowest[0]] = true
+		} else if len(tagged) == 1 {
+			dominant[tagged[0]] = true
+		}
+	}
+	var fields []namedField
+	for i, c := range candidates {
+		if dominant[i] {
+			fields = append(fields, c.namedField)
+		}
+	}
+	return fields
+}
+
+// fieldName returns the name that the struct field f is matched by and whether
+// that name comes from a json tag. It returns false if f is skipped because of
+// a json:"-" tag.
+func fieldName(f reflect.StructField, useJSON bool) (name string, tagged, ok bool) {
+	if useJSON {
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			return "", false, false
+		}
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return name, true, true
+		}
+	}
+	return f.Name, false, true
+}
+
+// fieldByIndex returns the field of the struct v at the given index sequence.
+// It returns false if the field is promoted through a nil pointer to an
+// embedded struct.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
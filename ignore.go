@@ -0,0 +1,103 @@
+package check
+
+import (
+	"reflect"
+	"strings"
+)
+
+// anyElement is the wildcard for a single index or map key in ignored paths.
+const anyElement = "[*]"
+
+// ignorePath adds path to the ignored paths. Paths may leave out the leading
+// dot of a top-level field, e.g. "User.Name" is the same as ".User.Name".
+func (c *comparer) ignorePath(path string) {
+	if path != "" && path[0] != '.' && path[0] != '[' {
+		path = "." + path
+	}
+	if strings.Contains(path, anyElement) {
+		c.ignorePatterns = append(c.ignorePatterns, path)
+		return
+	}
+	if c.ignorePaths == nil {
+		c.ignorePaths = make(map[string]bool)
+	}
+	c.ignorePaths[path] = true
+}
+
+// ignores reports whether the values v1 and v2 at the given path are skipped,
+// either because of their path or their type. Either value may be invalid if it
+// does not exist on its side.
+func (c *comparer) ignores(path string, v1, v2 reflect.Value) bool {
+	if c.ignorePaths[path] {
+		return true
+	}
+	for _, pattern := range c.ignorePatterns {
+		if pathMatches(pattern, path) {
+			return true
+		}
+	}
+	if len(c.ignoreTypes) > 0 {
+		if v1.IsValid() && c.ignoreTypes[v1.Type()] ||
+			v2.IsValid() && c.ignoreTypes[v2.Type()] {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoresPaths reports whether there are any IgnorePaths. Values that only
+// exist on one side must then be checked against them before two values can
+// be considered different.
+func (c *comparer) ignoresPaths() bool {
+	return len(c.ignorePaths) > 0 || len(c.ignorePatterns) > 0
+}
+
+// pathMatches reports whether path matches pattern, in which every [*] stands
+// for one arbitrary index or map key.
+func pathMatches(pattern, path string) bool {
+	for pattern != "" {
+		if strings.HasPrefix(pattern, anyElement) {
+			n := elementLen(path)
+			if n == 0 {
+				return false
+			}
+			pattern, path = pattern[len(anyElement):], path[n:]
+			continue
+		}
+		if path == "" || pattern[0] != path[0] {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return path == ""
+}
+
+// elementLen returns the length of the index or map key, including its square
+// brackets, at the start of path, or 0 if path does not start with one. Map
+// keys are formatted with %#v so they can contain brackets and quoted strings
+// themselves.
+func elementLen(path string) int {
+	if path == "" || path[0] != '[' {
+		return 0
+	}
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '[', '{', '(':
+			depth++
+		case ']', '}', ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'', '`':
+			quote := path[i]
+			for i++; i < len(path) && path[i] != quote; i++ {
+				if path[i] == '\\' && quote != '`' {
+					i++
+				}
+			}
+		}
+	}
+	return 0
+}
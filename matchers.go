@@ -0,0 +1,225 @@
+package check
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+)
+
+// Matcher is a placeholder that can be put into the expected value of Eq and
+// the other check functions to accept a whole range of values, e.g.
+//
+//	check.EqOpt(t, user, map[string]interface{}{
+//		"ID":   check.MatchRegexp("^id-"),
+//		"Name": "Ann",
+//		"Age":  check.Between(18, 99),
+//		"Tags": check.Len(3),
+//	}, check.CompareStructsToMaps())
+//
+// Matchers can be used wherever the value that they are compared to can be
+// stored in an interface{}, e.g. in a []interface{}, a map[string]interface{}
+// or an interface{} struct field. They can also be passed as a whole.
+type Matcher struct {
+	desc string
+	// match is called with the value that the Matcher is compared to. If the
+	// value is an interface, v is its dynamic value, a nil interface is
+	// passed as an invalid reflect.Value.
+	match func(c *comparer, v reflect.Value, path string) bool
+}
+
+// GoString returns the code that creates m so that error messages show which
+// Matcher did not match.
+func (m Matcher) GoString() string {
+	return m.desc
+}
+
+var matcherType = reflect.TypeOf(Matcher{})
+
+// asMatcher returns the Matcher in v, if v is one or is an interface that
+// contains one.
+func asMatcher(v reflect.Value) (Matcher, bool) {
+	v = dynamicValue(v)
+	if !v.IsValid() || v.Type() != matcherType {
+		return Matcher{}, false
+	}
+	v, ok := interfaceable(v)
+	if !ok {
+		return Matcher{}, false
+	}
+	return v.Interface().(Matcher), true
+}
+
+// dynamicValue returns the value inside of v if v is an interface. If v is a
+// nil interface, the result is an invalid reflect.Value.
+func dynamicValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// Any matches any value, including nil.
+func Any() Matcher {
+	return Matcher{
+		desc: "check.Any()",
+		match: func(*comparer, reflect.Value, string) bool {
+			return true
+		},
+	}
+}
+
+// NotZero matches all values except for nil and the zero value of their type.
+// Unless StrictNil is set, empty slices and maps are considered zero as well.
+func NotZero() Matcher {
+	return Matcher{
+		desc: "check.NotZero()",
+		match: func(c *comparer, v reflect.Value, _ string) bool {
+			return v.IsValid() && !c.isZero(v)
+		},
+	}
+}
+
+// Between matches integer and float values in the range [lo, hi], including
+// lo and hi. The values are compared like Eq compares numbers, e.g. using an
+// epsilon for floats. Between panics if lo or hi are not numbers.
+func Between(lo, hi interface{}) Matcher {
+	for _, x := range []interface{}{lo, hi} {
+		v := reflect.ValueOf(x)
+		if x == nil || !(isInteger(v) || isFloat(v)) {
+			panic(fmt.Sprintf("check: Between needs numbers but got %#v", x))
+		}
+	}
+	loValue, hiValue := reflect.ValueOf(lo), reflect.ValueOf(hi)
+	return Matcher{
+		desc: fmt.Sprintf("check.Between(%#v, %#v)", lo, hi),
+		match: func(c *comparer, v reflect.Value, path string) bool {
+			if !v.IsValid() || !(isInteger(v) || isFloat(v)) {
+				return false
+			}
+			aboveLo, ok1 := compareNumbers(loValue, v)
+			belowHi, ok2 := compareNumbers(v, hiValue)
+			return ok1 && ok2 &&
+				(aboveLo <= 0 || c.quietEqual(v, loValue, path)) &&
+				(belowHi <= 0 || c.quietEqual(v, hiValue, path))
+		},
+	}
+}
+
+// MatchRegexp matches strings, byte slices and rune slices that contain a
+// match of the regular expression expr. Use ^ and $ to match the whole string.
+// MatchRegexp panics if expr cannot be compiled.
+func MatchRegexp(expr string) Matcher {
+	re := regexp.MustCompile(expr)
+	return Matcher{
+		desc: fmt.Sprintf("check.MatchRegexp(%q)", expr),
+		match: func(_ *comparer, v reflect.Value, _ string) bool {
+			return v.IsValid() && canBeString(v) && re.Match(toBytes(v))
+		},
+	}
+}
+
+// Len matches arrays, slices, maps, strings and channels of length n. Unless
+// StrictNil is set, Len(0) also matches an untyped nil.
+func Len(n int) Matcher {
+	return Matcher{
+		desc: fmt.Sprintf("check.Len(%d)", n),
+		match: func(c *comparer, v reflect.Value, _ string) bool {
+			if !v.IsValid() {
+				return n == 0 && !c.strictNil
+			}
+			switch v.Kind() {
+			case reflect.Array, reflect.Slice, reflect.Map, reflect.String, reflect.Chan:
+				return v.Len() == n
+			}
+			return false
+		},
+	}
+}
+
+// isZero reports whether v is the zero value of its type. Unless StrictNil is
+// set, empty slices and maps are considered zero as well.
+func (c *comparer) isZero(v reflect.Value) bool {
+	if !c.strictNil && isNilOrEmpty(v) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(v.Float()) == 0
+	case reflect.Complex64, reflect.Complex128:
+		x := v.Complex()
+		return math.Float64bits(real(x)) == 0 && math.Float64bits(imag(x)) == 0
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !c.isZero(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !c.isZero(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Chan, reflect.Func, reflect.Interface:
+		return v.IsNil()
+	case reflect.UnsafePointer:
+		return v.Pointer() == 0
+	}
+	return false
+}
+
+// compareNumbers returns -1, 0 or 1 if the integer or float a is less than,
+// equal to or greater than the integer or float b. Integers are compared
+// exactly. It returns false if the values cannot be ordered, i.e. for NaN.
+func compareNumbers(a, b reflect.Value) (int, bool) {
+	switch {
+	case isSignedInteger(a) && isSignedInteger(b):
+		return compareInts(a.Int() < b.Int(), a.Int() > b.Int()), true
+	case isUnsignedInteger(a) && isUnsignedInteger(b):
+		return compareInts(a.Uint() < b.Uint(), a.Uint() > b.Uint()), true
+	case isSignedInteger(a) && isUnsignedInteger(b):
+		if a.Int() < 0 {
+			return -1, true
+		}
+		return compareInts(uint64(a.Int()) < b.Uint(), uint64(a.Int()) > b.Uint()), true
+	case isUnsignedInteger(a) && isSignedInteger(b):
+		cmp, ok := compareNumbers(b, a)
+		return -cmp, ok
+	}
+	x, y := numberToFloat64(a), numberToFloat64(b)
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return 0, false
+	}
+	return compareInts(x < y, x > y), true
+}
+
+func compareInts(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+func numberToFloat64(v reflect.Value) float64 {
+	if isFloat(v) {
+		return v.Float()
+	}
+	return intToFloat64(v)
+}
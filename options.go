@@ -0,0 +1,335 @@
+package check
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Option changes how EqOpt, NeqOpt and their variants compare values. Options
+// are applied in order, if two options set the same thing, the last one wins.
+type Option func(*comparer)
+
+// Message prefixes the error message of a failed check with the concatenation
+// of msg, just like the msg parameters of Eq do. Message panics if msg contains
+// an Option, which happens when options are passed to Eq instead of EqOpt.
+func Message(msg ...interface{}) Option {
+	for _, m := range msg {
+		if _, ok := m.(Option); ok {
+			panic("check: an Option was passed as a message, use the Opt " +
+				"functions like EqOpt to pass options")
+		}
+	}
+	return func(c *comparer) {
+		c.msg = msg
+	}
+}
+
+// Epsilon sets the absolute tolerance for float and complex values. Two values
+// are equal if their absolute difference is less than or equal to epsilon. The
+// default is 1e-6 with a wider tolerance for float32 values, as described for
+// Eq. Setting an epsilon removes that exception. Use 0 to compare for exact
+// equality.
+// Without any tolerance, integers are compared exactly to float and complex
+// values. With a tolerance, integers are converted to float64 first, which
+// rounds integers beyond 2^53.
+func Epsilon(epsilon float64) Option {
+	return func(c *comparer) {
+		c.eps = epsilon
+		c.defaultEps = false
+	}
+}
+
+// RelativeEpsilon sets a relative tolerance for float and complex values. Two
+// values a and b are equal if
+//
+//	|a-b| <= epsilon * max(|a|, |b|)
+//
+// It replaces the default epsilon of 1e-6, e.g.
+//
+//	check.EqOpt(t, 1e-12, 5e-12, check.RelativeEpsilon(1e-9))
+//
+// fails. If an Epsilon is given as well, values that are equal under either
+// tolerance are considered equal.
+func RelativeEpsilon(epsilon float64) Option {
+	return func(c *comparer) {
+		c.relEps = epsilon
+	}
+}
+
+// ULPs sets a tolerance in units in the last place for float and complex
+// values. Two values are equal if there are fewer than n other representable
+// values between them, e.g. ULPs(1) only accepts neighboring values. If one of
+// the compared values is a float32 or complex64, float32 values are counted.
+// It replaces the default epsilon of 1e-6. If an Epsilon is given as well,
+// values that are equal under either tolerance are considered equal.
+func ULPs(n uint64) Option {
+	return func(c *comparer) {
+		c.ulps = n
+	}
+}
+
+// IntegerTolerance applies the absolute Epsilon to integers as well, which are
+// compared exactly by default. Two integers of any signed or unsigned types
+// are equal if their difference is at most epsilon, e.g.
+//
+//	check.EqOpt(t, timestampMillis, want, check.Epsilon(2), check.IntegerTolerance())
+//
+// accepts timestamps that are off by up to 2 milliseconds. This includes the
+// elements of byte and rune slices, which are then not compared as strings. The
+// relative and ULP tolerances do not apply to integers.
+func IntegerTolerance() Option {
+	return func(c *comparer) {
+		c.intTolerance = true
+	}
+}
+
+// StrictFloats compares special float values bit for bit. NaNs are only equal
+// if they have the same bits, i.e. the same sign and payload, +0 and -0 are not
+// equal and infinities are only equal to themselves. This also applies to the
+// real and imaginary parts of complex values. Use it to check that a
+// computation produces exactly these values. Values that are not special are
+// still compared using the tolerances, e.g. -0 is equal to 1e-9 with the
+// default epsilon.
+func StrictFloats() Option {
+	return func(c *comparer) {
+		c.floatMode = strictFloats
+	}
+}
+
+// IEEEFloats compares special float values like the == operator does, as
+// defined by IEEE 754. NaN is not equal to anything, not even to itself, and +0
+// is equal to -0. By default NaN is equal to NaN.
+func IEEEFloats() Option {
+	return func(c *comparer) {
+		c.floatMode = ieeeFloats
+	}
+}
+
+// ComplexModulus compares complex values by the distance between them in the
+// complex plane. Two complex values a and b are equal if
+//
+//	|a-b| <= epsilon
+//
+// or, with a RelativeEpsilon, if
+//
+//	|a-b| <= relative epsilon * max(|a|, |b|)
+//
+// The modulus is also used when comparing complex values to integers and
+// floats. By default the real and imaginary parts are compared separately.
+// ULPs do not apply to complex values in this mode. Values with NaN or infinite
+// parts are still compared part by part.
+func ComplexModulus() Option {
+	return func(c *comparer) {
+		c.complexModulus = true
+	}
+}
+
+// CompareFieldsByName allows structs of different types to be equal. Their
+// exported fields are matched by name and compared, unexported fields are
+// ignored. A field that only exists in one of the structs makes them differ.
+// The fields of embedded structs are promoted, as if they were declared in the
+// outer struct, so a struct with the fields ID and Name can be equal to a
+// struct that embeds a struct with an ID field next to a Name field. Pointers
+// to structs of different types are compared by the structs they point to.
+// Structs of the same type are still compared field by field, including
+// unexported fields.
+func CompareFieldsByName() Option {
+	return func(c *comparer) {
+		c.fieldsByName = true
+	}
+}
+
+// CompareStructsToMaps allows a struct to be equal to a map with string keys,
+// e.g. a map[string]interface{}. The exported fields of the struct are matched
+// with the map keys by name and compared, unexported fields are ignored. A field
+// without a key or a key without a field makes them differ. The fields of
+// embedded structs are promoted, as if they were declared in the outer struct.
+// Pointers to structs are compared to maps by the structs they point to. This
+// also applies to nested values, e.g. a slice of structs can be equal to a
+// []interface{} of maps.
+func CompareStructsToMaps() Option {
+	return func(c *comparer) {
+		c.structsToMaps = true
+	}
+}
+
+// UseJSONTags makes CompareStructsToMaps use the names in the json tags of
+// struct fields as map keys, like encoding/json does. Fields tagged with
+// json:"-" are ignored, fields without a name in their tag use their field
+// name. Embedded structs with a name in their json tag are matched as a whole
+// instead of having their fields promoted. Options like omitempty are not
+// taken into account, every field needs a key.
+func UseJSONTags() Option {
+	return func(c *comparer) {
+		c.jsonTags = true
+	}
+}
+
+// IgnoreUnexported skips unexported struct fields when comparing structs.
+func IgnoreUnexported() Option {
+	return func(c *comparer) {
+		c.ignoreUnexported = true
+	}
+}
+
+// IgnoreForeignUnexported skips unexported struct fields of types that are
+// declared in other packages than the test that calls IgnoreForeignUnexported.
+// Use it to compare values that contain e.g. caches or mutexes of third-party
+// types while still comparing the unexported fields of your own types. Tests
+// in an external test package, i.e. package x_test, count as package x.
+func IgnoreForeignUnexported() Option {
+	pkg := callerPackage()
+	return func(c *comparer) {
+		c.ownPackage = pkg
+	}
+}
+
+// callerPackage returns the import path of the package of the function that
+// called the function that calls callerPackage, without a _test suffix.
+func callerPackage() string {
+	pc := make([]uintptr, 1)
+	if runtime.Callers(3, pc) == 0 {
+		return ""
+	}
+	f := runtime.FuncForPC(pc[0] - 1)
+	if f == nil {
+		return ""
+	}
+	// Function names look like "github.com/user/pkg.Func", "pkg.(*T).Method"
+	// or "pkg.Func.func1". Dots in the last element of the import path are
+	// escaped as %2e.
+	name := f.Name()
+	slash := strings.LastIndex(name, "/") + 1
+	dot := strings.Index(name[slash:], ".")
+	if dot == -1 {
+		return ""
+	}
+	pkg := strings.Replace(name[:slash+dot], "%2e", ".", -1)
+	return strings.TrimSuffix(pkg, "_test")
+}
+
+// Comparer makes this check compare values of type T with f, which must be a
+// function of the form
+//
+//	func(a, b T) bool
+//
+// It works like RegisterComparer but only for the check that it is passed to,
+// and it overrides a registered function for the same type. Comparer panics if
+// f does not have the right form.
+func Comparer(f interface{}) Option {
+	t, fv := comparerFunc("Comparer", f)
+	return func(c *comparer) {
+		if c.comparers == nil {
+			c.comparers = make(map[reflect.Type]reflect.Value)
+		}
+		c.comparers[t] = fv
+	}
+}
+
+// Transform normalizes values before they are compared. f must be a function of
+// the form
+//
+//	func(T) U
+//
+// Every value of type T, on either side and at any depth, is replaced by the
+// result of f before it is compared, e.g.
+//
+//	check.Transform(strings.TrimSpace)
+//
+// compares strings without leading and trailing white space. If the results
+// differ, the error message shows both the original and the transformed
+// values. Transform panics if f does not have the right form.
+func Transform(f interface{}) Option {
+	t, fv := transformerFunc(f)
+	return func(c *comparer) {
+		if c.transformers == nil {
+			c.transformers = make(map[reflect.Type]reflect.Value)
+		}
+		c.transformers[t] = fv
+	}
+}
+
+// IgnoreEqualMethods compares values structurally even if their type has an
+//
+//	Equal(T) bool
+//
+// or
+//
+//	Cmp(T) int
+//
+// method. By default these methods are used to compare two values of the same
+// type T, e.g. so that two time.Time values for the same instant in different
+// locations are equal.
+func IgnoreEqualMethods() Option {
+	return func(c *comparer) {
+		c.ignoreEqualMethods = true
+	}
+}
+
+// IgnorePaths skips the values at the given paths. Paths are written the same
+// way that differences are reported in error messages, so they can be copied
+// from a failed test, e.g.
+//
+//	check.IgnorePaths(`.Orders[3].Items["sku-1"].Price`)
+//
+// The leading dot may be left out. Use [*] to match any index or map key, e.g.
+//
+//	check.IgnorePaths("User.CreatedAt", "Items[*].ID")
+func IgnorePaths(paths ...string) Option {
+	return func(c *comparer) {
+		for _, path := range paths {
+			c.ignorePath(path)
+		}
+	}
+}
+
+// IgnoreTypes skips all values that have the same type as one of the given
+// values, e.g.
+//
+//	check.IgnoreTypes(time.Time{}, sync.Mutex{})
+//
+// ignores all time.Time and sync.Mutex values. A nil *time.Time still differs
+// from a non-nil one, add &time.Time{} to ignore the pointers as well.
+func IgnoreTypes(values ...interface{}) Option {
+	return func(c *comparer) {
+		if c.ignoreTypes == nil {
+			c.ignoreTypes = make(map[reflect.Type]bool)
+		}
+		for _, v := range values {
+			if v != nil {
+				c.ignoreTypes[reflect.TypeOf(v)] = true
+			}
+		}
+	}
+}
+
+// Unordered compares arrays and slices without regard to the order of their
+// elements. Every element on one side must be equal to a distinct element on
+// the other side, like in a multiset. If they differ, the error message lists
+// the elements of b that are missing in a and the extra elements in a.
+func Unordered() Option {
+	return func(c *comparer) {
+		c.unordered = true
+	}
+}
+
+// NilEqualsEmpty makes nil and empty values equal, which is the default. An
+// untyped nil or a nil interface is equal to nil pointers, channels, functions
+// and interfaces and to nil or empty slices and maps. Nil slices and maps are
+// also equal to empty slices and maps of the same type. Use it to undo a
+// StrictNil option.
+func NilEqualsEmpty() Option {
+	return func(c *comparer) {
+		c.strictNil = false
+	}
+}
+
+// StrictNil compares nil values like reflect.DeepEqual does. An untyped nil is
+// only equal to another untyped nil, a nil interface is only equal to another
+// nil interface and nil slices and maps are not equal to empty slices and maps.
+func StrictNil() Option {
+	return func(c *comparer) {
+		c.strictNil = true
+	}
+}
@@ -0,0 +1,91 @@
+package check
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldTag holds the settings from the check tag of a struct field. Domain
+// types can use it to declare how their fields are compared, e.g.
+//
+//	type Measurement struct {
+//		ID     int       `check:"-"`
+//		Value  float64   `check:"eps=1e-3"`
+//		Tags   []string  `check:"unordered"`
+//		Series []float64 `check:"eps=0.1,unordered"`
+//	}
+type fieldTag struct {
+	ignore    bool     // check:"-"
+	eps       *float64 // check:"eps=1e-3"
+	unordered bool     // check:"unordered"
+}
+
+// parseFieldTag parses the check tag of f. It panics if the tag is invalid so
+// that typos do not go unnoticed.
+func parseFieldTag(f reflect.StructField) fieldTag {
+	var tag fieldTag
+	s, ok := f.Tag.Lookup("check")
+	if !ok {
+		return tag
+	}
+	if s == "-" {
+		tag.ignore = true
+		return tag
+	}
+	for _, option := range strings.Split(s, ",") {
+		option = strings.TrimSpace(option)
+		switch {
+		case option == "":
+			// Empty tags and trailing commas are harmless.
+		case option == "unordered":
+			tag.unordered = true
+		case strings.HasPrefix(option, "eps="):
+			eps, err := strconv.ParseFloat(strings.TrimPrefix(option, "eps="), 64)
+			if err != nil || eps < 0 {
+				panic(fmt.Sprintf(
+					"check: invalid epsilon in tag %q of field %s", s, f.Name,
+				))
+			}
+			tag.eps = &eps
+		default:
+			panic(fmt.Sprintf(
+				"check: unknown option %q in tag %q of field %s", option, s, f.Name,
+			))
+		}
+	}
+	return tag
+}
+
+// merge combines the tags of two fields of different struct types that are
+// compared to each other.
+func (t fieldTag) merge(other fieldTag) fieldTag {
+	t.ignore = t.ignore || other.ignore
+	t.unordered = t.unordered || other.unordered
+	if t.eps == nil {
+		t.eps = other.eps
+	}
+	return t
+}
+
+// fieldEqual compares the struct field values v1 and v2 with the settings of
+// their check tag. These settings apply to the field values and everything
+// inside of them.
+func (c *comparer) fieldEqual(tag fieldTag, v1, v2 reflect.Value, path string) bool {
+	if tag.ignore {
+		return true
+	}
+	eps, defaultEps, unordered := c.eps, c.defaultEps, c.unordered
+	defer func() {
+		c.eps, c.defaultEps, c.unordered = eps, defaultEps, unordered
+	}()
+	if tag.eps != nil {
+		c.eps = *tag.eps
+		c.defaultEps = false
+	}
+	if tag.unordered {
+		c.unordered = true
+	}
+	return c.deepValueEqual(v1, v2, path)
+}
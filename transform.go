@@ -0,0 +1,85 @@
+package check
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// transformerFunc checks that f has the form func(T) U and returns T and f.
+func transformerFunc(f interface{}) (reflect.Type, reflect.Value) {
+	fv := reflect.ValueOf(f)
+	if f == nil || fv.Kind() != reflect.Func || fv.IsNil() {
+		panic(fmt.Sprintf("check: Transform needs a func(T) U but got %#v", f))
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 || ft.IsVariadic() || ft.NumOut() != 1 {
+		panic(fmt.Sprintf("check: Transform needs a func(T) U but got a %v", ft))
+	}
+	return ft.In(0), fv
+}
+
+// transform applies the Transform options to v1 and v2. Values without a
+// transformer are returned as they are. It also returns the input types of the
+// transformers applied to v1 and v2, which are nil if a value was not
+// transformed.
+func (c *comparer) transform(v1, v2 reflect.Value) (t1, t2 reflect.Value, applied [2]reflect.Type) {
+	if len(c.transformers) == 0 {
+		return v1, v2, applied
+	}
+	apply := func(v reflect.Value, side int) (reflect.Value, reflect.Type) {
+		t := v.Type()
+		// A transformer is never applied inside its own result. Otherwise a
+		// transformer like strings.Fields would transform the strings that it
+		// returns, and those results again, without end.
+		if c.activeTransformers[side][t] {
+			return v, nil
+		}
+		f, ok := c.transformers[t]
+		if !ok {
+			return v, nil
+		}
+		v, ok = interfaceable(v)
+		if !ok {
+			return v, nil
+		}
+		// Store the result in a variable so that it is addressable, see
+		// addressable.
+		result := reflect.New(f.Type().Out(0)).Elem()
+		result.Set(f.Call([]reflect.Value{v})[0])
+		return result, t
+	}
+	t1, applied[0] = apply(v1, 0)
+	t2, applied[1] = apply(v2, 1)
+	return t1, t2, applied
+}
+
+// transformedEqual compares the transformed values t1 and t2 of the original
+// values v1 and v2. The applied transformers are disabled on their side while
+// comparing t1 and t2. Differences are preceded by the original values so both
+// can be seen in error messages.
+func (c *comparer) transformedEqual(v1, v2, t1, t2 reflect.Value, applied [2]reflect.Type, path string) bool {
+	for side, t := range applied {
+		if t != nil {
+			if c.activeTransformers[side] == nil {
+				c.activeTransformers[side] = make(map[reflect.Type]bool)
+			}
+			c.activeTransformers[side][t] = true
+		}
+	}
+	n := len(c.diffs)
+	equal := c.deepValueEqual(t1, t2, path)
+	for side, t := range applied {
+		delete(c.activeTransformers[side], t)
+	}
+	if equal {
+		return true
+	}
+	if c.record && len(c.diffs) > n {
+		original := difference{
+			path: path,
+			text: formatValue(v1) + " != " + formatValue(v2) + ", transformed:",
+		}
+		c.diffs = append(c.diffs[:n], append([]difference{original}, c.diffs[n:]...)...)
+	}
+	return false
+}